@@ -1,18 +1,214 @@
 package main
 
 import (
+	"booker-be/internal/config"
 	"booker-be/internal/database"
+	"booker-be/internal/events"
+	"booker-be/internal/jobs"
+	"booker-be/internal/logging"
+	"booker-be/internal/metrics"
+	"booker-be/internal/notify"
 	"booker-be/internal/server"
 	"booker-be/internal/session"
+	"booker-be/internal/storage"
+	"booker-be/internal/version"
+	"booker-be/internal/webhooks"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
 )
 
 func main() {
+	// "booker config print [flags]" shows the effective configuration
+	args := os.Args[1:]
+	if len(args) >= 2 && args[0] == "config" && args[1] == "print" {
+		cfg := loadConfig(args[2:])
+		cfg.Print(os.Stdout)
+		return
+	}
+
+	cfg := loadConfig(args)
+
+	logger, err := logging.New(os.Stderr, cfg.Log.Level, cfg.Log.Format)
+	if err != nil {
+		panic(err)
+	}
+	slog.SetDefault(logger)
+
+	// Shut down gracefully on Ctrl+C and on SIGTERM, which is sent during deploys
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Initialize the database service
-	db := database.New()
+	db := database.New(cfg.Database.Path)
 
 	// Initialize the session store
 	store := session.NewStore()
 
-	// Create a new Gin router
-	server.StartServer(db, store)
+	// Initialize the attachment storage
+	blobs, err := storage.NewLocalStore(cfg.Storage.AttachmentsDir)
+	if err != nil {
+		panic(err)
+	}
+
+	// Initialize the hub for real-time group events
+	hub := events.NewHub(256)
+
+	// Background work keeps running until the server has stopped, so that
+	// requests finishing during shutdown still get their webhooks and emails
+	background, stopBackground := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	runWorker := func(run func(context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(background)
+		}()
+	}
+
+	// Queue webhook deliveries for published events and send them in the background
+	dispatcher := webhooks.NewDispatcher(db)
+	hub.OnPublish(dispatcher.Enqueue)
+	runWorker(dispatcher.Run)
+
+	// Email booking changes to group members
+	notifier, err := notify.New(db, notify.NewSender(cfg.Mail))
+	if err != nil {
+		panic(err)
+	}
+	hub.OnPublish(notifier.OnEvent)
+	runWorker(notifier.Run)
+
+	registerMetrics(db, store, hub)
+
+	// Run the periodic background jobs
+	scheduler := jobs.NewScheduler(db)
+	if err := registerJobs(scheduler, cfg, db, store, notifier); err != nil {
+		panic(err)
+	}
+	scheduler.Start(background)
+
+	// Serve requests until a shutdown signal arrives
+	slog.Info("Listening", "addr", cfg.Server.Addr, "version", version.Version)
+	serveErr := server.StartServer(ctx, cfg, db, store, blobs, hub, scheduler)
+	if serveErr != nil {
+		slog.Error("Server error", "error", serveErr)
+	}
+
+	// Stop everything that uses the database before closing it
+	slog.Info("Stopping background jobs")
+	stopBackground()
+	scheduler.Wait()
+	workers.Wait()
+
+	// The session store lives in memory and has nothing to release; its
+	// cleanup is one of the scheduled jobs stopped above
+
+	slog.Info("Closing database")
+	if err := db.Close(); err != nil {
+		slog.Error("Error closing database", "error", err)
+	}
+
+	if serveErr != nil {
+		os.Exit(1)
+	}
+}
+
+// loadConfig loads the configuration from the config file, environment and flags,
+// exiting when it is invalid
+func loadConfig(args []string) config.Config {
+	cfg, err := config.Load(args)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid configuration:")
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	return cfg
+}
+
+// registerMetrics adds the metrics that aren't recorded where they happen
+func registerMetrics(db database.Service, store *session.Store, hub *events.Hub) {
+	metrics.Default.NewGaugeFunc("booker_sessions_active", "Sessions that have not expired.", func() float64 {
+		return float64(store.ActiveSessions())
+	})
+	metrics.Default.NewGaugeFunc("go_goroutines", "Number of goroutines that currently exist.", func() float64 {
+		return float64(runtime.NumGoroutine())
+	})
+
+	published := metrics.Default.NewCounter("booker_events_published_total", "Group events published, such as booking.created, by type.", "type")
+	hub.OnPublish(func(e events.Event) {
+		published.Inc(e.Type)
+	})
+
+	users := metrics.Default.NewGauge("booker_users", "Registered users.")
+	groups := metrics.Default.NewGauge("booker_groups", "Groups.")
+	properties := metrics.Default.NewGauge("booker_properties", "Properties.")
+	bookings := metrics.Default.NewGauge("booker_bookings", "Bookings.")
+	bookingsCreated := metrics.Default.NewGauge("booker_bookings_created_last_24h", "Bookings created in the last 24 hours.")
+	metrics.Default.OnScrape(func() {
+		stats, err := db.GetStats(time.Now().Add(-24 * time.Hour).Unix())
+		if err != nil {
+			slog.Error("Error retrieving stats for metrics", "error", err)
+			return
+		}
+		users.Set(float64(stats.Users))
+		groups.Set(float64(stats.Groups))
+		properties.Set(float64(stats.Properties))
+		bookings.Set(float64(stats.Bookings))
+		bookingsCreated.Set(float64(stats.BookingsCreatedSince))
+	})
+}
+
+func registerJobs(scheduler *jobs.Scheduler, cfg config.Config, db database.Service, store *session.Store, notifier *notify.Notifier) error {
+	for _, job := range []jobs.Job{
+		{
+			Name:     "group-codes-cleanup",
+			Schedule: jobs.Every(time.Hour),
+			Run: func(ctx context.Context) error {
+				db := db.WithContext(ctx)
+				return db.CleanUpExpiredGroupCodes()
+			},
+		},
+		{
+			Name:     "idempotency-keys-cleanup",
+			Schedule: jobs.Every(time.Hour),
+			Run: func(ctx context.Context) error {
+				db := db.WithContext(ctx)
+				return db.CleanUpIdempotencyKeys(time.Now().Add(-cfg.Server.IdempotencyKeyTTL).Unix())
+			},
+		},
+		{
+			Name:     "sessions-cleanup",
+			Schedule: jobs.Every(10 * time.Minute),
+			Local:    true,
+			Run: func(context.Context) error {
+				store.CleanupExpiredSessions()
+				return nil
+			},
+		},
+		{
+			// Tomorrow's arrivals, every day at the configured local hour
+			Name:     "arrivals-digest",
+			Schedule: jobs.MustParse(fmt.Sprintf("0 %d * * *", cfg.Jobs.DigestHour)),
+			Run: func(context.Context) error {
+				return notifier.SendArrivalsDigests(time.Now().AddDate(0, 0, 1).Format("2006-01-02"))
+			},
+		},
+	} {
+		if err := scheduler.Add(job); err != nil {
+			return err
+		}
+	}
+	return nil
 }
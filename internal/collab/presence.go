@@ -0,0 +1,113 @@
+package collab
+
+import (
+	"sort"
+	"sync"
+)
+
+// Viewer is a user with a group's calendar open
+type Viewer struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	Month    string `json:"month"` // YYYY-MM, empty until the client reports it
+}
+
+// Presence tracks who is viewing the calendars of each group.
+// Changes are signalled rather than queued: a session only learns that the
+// presence of its group changed and reads the current viewers when it is ready,
+// so a slow client never holds up the others.
+type Presence struct {
+	mu     sync.Mutex
+	groups map[string]map[*Session]struct{} // group ID -> sessions
+}
+
+// Session is one connection's entry in a group's presence
+type Session struct {
+	presence *Presence
+	groupID  string
+	viewer   Viewer // guarded by presence.mu
+	changed  chan struct{}
+}
+
+func NewPresence() *Presence {
+	return &Presence{groups: make(map[string]map[*Session]struct{})}
+}
+
+// Join adds a viewer to a group and notifies the group's sessions
+func (p *Presence) Join(groupID string, v Viewer) *Session {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s := &Session{presence: p, groupID: groupID, viewer: v, changed: make(chan struct{}, 1)}
+	if p.groups[groupID] == nil {
+		p.groups[groupID] = make(map[*Session]struct{})
+	}
+	p.groups[groupID][s] = struct{}{}
+	p.notify(groupID)
+	return s
+}
+
+// SetMonth records the month the viewer is looking at
+func (s *Session) SetMonth(month string) {
+	p := s.presence
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s.viewer.Month == month {
+		return
+	}
+	s.viewer.Month = month
+	p.notify(s.groupID)
+}
+
+// Leave removes the viewer from the group's presence
+func (s *Session) Leave() {
+	p := s.presence
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sessions := p.groups[s.groupID]
+	if _, ok := sessions[s]; !ok {
+		return
+	}
+	delete(sessions, s)
+	if len(sessions) == 0 {
+		delete(p.groups, s.groupID)
+		return
+	}
+	p.notify(s.groupID)
+}
+
+// Changed receives a value whenever the viewers of the session's group may have changed
+func (s *Session) Changed() <-chan struct{} {
+	return s.changed
+}
+
+// Viewers returns everyone currently viewing the session's group, ordered by username
+func (s *Session) Viewers() []Viewer {
+	p := s.presence
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	viewers := make([]Viewer, 0, len(p.groups[s.groupID]))
+	for other := range p.groups[s.groupID] {
+		viewers = append(viewers, other.viewer)
+	}
+	sort.Slice(viewers, func(i, j int) bool {
+		if viewers[i].Username != viewers[j].Username {
+			return viewers[i].Username < viewers[j].Username
+		}
+		return viewers[i].Month < viewers[j].Month
+	})
+	return viewers
+}
+
+// notify signals every session of a group without blocking. p.mu must be held.
+func (p *Presence) notify(groupID string) {
+	for s := range p.groups[groupID] {
+		select {
+		case s.changed <- struct{}{}:
+		default:
+			// A change is already pending and the session will read the latest viewers
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package config
+
+import (
+	"booker-be/internal/cors"
+	"booker-be/internal/logging"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"strings"
+	"time"
+
+	_ "github.com/joho/godotenv/autoload"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Config is the configuration of the server. Every setting has a key in the
+// config file, an environment variable and a command-line flag named after the
+// key, see Load.
+type Config struct {
+	Server   Server   `key:"server"`
+	Database Database `key:"database"`
+	Storage  Storage  `key:"storage"`
+	Auth     Auth     `key:"auth"`
+	CORS     CORS     `key:"cors"`
+	Mail     Mail     `key:"mail"`
+	Jobs     Jobs     `key:"jobs"`
+	Log      Log      `key:"log"`
+	Metrics  Metrics  `key:"metrics"`
+
+	sources map[string]string // Where each setting that isn't a default came from
+}
+
+// Server configures the HTTP server
+type Server struct {
+	Addr              string        `key:"addr" env:"LISTEN_ADDR" usage:"address to listen on"`
+	ReadHeaderTimeout time.Duration `key:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT" usage:"time allowed to read request headers"`
+	ReadTimeout       time.Duration `key:"read_timeout" env:"HTTP_READ_TIMEOUT" usage:"time allowed to read a whole request"`
+	WriteTimeout      time.Duration `key:"write_timeout" env:"HTTP_WRITE_TIMEOUT" usage:"time allowed to write a response; event streams and WebSockets are exempt"`
+	IdleTimeout       time.Duration `key:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" usage:"how long idle keep-alive connections are kept open"`
+	ShutdownTimeout   time.Duration `key:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" usage:"how long in-flight requests may take to finish on shutdown"`
+	IdempotencyKeyTTL time.Duration `key:"idempotency_key_ttl" env:"IDEMPOTENCY_KEY_TTL" usage:"how long the response to a request with an Idempotency-Key header is replayed on retries"`
+}
+
+type Database struct {
+	Path string `key:"path" env:"DATABASE_PATH" usage:"SQLite database file"`
+}
+
+type Storage struct {
+	AttachmentsDir string `key:"attachments_dir" env:"ATTACHMENTS_DIR" usage:"directory for uploaded attachments"`
+}
+
+type Auth struct {
+	SessionDuration   time.Duration `key:"session_duration" env:"SESSION_DURATION" usage:"how long a login stays valid"`
+	GroupCodeDuration time.Duration `key:"group_code_duration" env:"GROUP_CODE_DURATION" usage:"how long a group invitation code stays valid"`
+	BcryptCost        int           `key:"bcrypt_cost" env:"BCRYPT_COST" usage:"bcrypt cost factor for password hashes"`
+	AdminUserIDs      []string      `key:"admin_user_ids" env:"ADMIN_USER_IDS" usage:"user IDs of administrators, comma separated"`
+}
+
+// CORS lists the origins allowed to call the API. Origins may be patterns such as
+// https://*.example.com, matching every subdomain.
+type CORS struct {
+	AllowedOrigins []string      `key:"allowed_origins" env:"CORS_ALLOWED_ORIGINS" usage:"origins allowed to call the API, comma separated; https://*.example.com matches subdomains"`
+	FrontendURL    string        `key:"frontend_url" env:"FRONTEND_URL" usage:"origin of the production frontend, allowed in addition to allowed_origins"`
+	MaxAge         time.Duration `key:"max_age" env:"CORS_MAX_AGE" usage:"how long browsers may cache preflight responses"`
+}
+
+// Mail configures outgoing email. Without an SMTP server messages are written
+// to OutboxDir, or to the log, for development.
+type Mail struct {
+	From         string `key:"from" env:"MAIL_FROM" usage:"sender address of notification emails"`
+	SMTPAddr     string `key:"smtp_addr" env:"SMTP_ADDR" usage:"SMTP server (host:port)"`
+	SMTPUsername string `key:"smtp_username" env:"SMTP_USERNAME" usage:"SMTP username, authentication is skipped when empty"`
+	SMTPPassword string `key:"smtp_password" env:"SMTP_PASSWORD" usage:"SMTP password" secret:"true"`
+	OutboxDir    string `key:"outbox_dir" env:"MAIL_OUTBOX_DIR" usage:"directory for outgoing emails when no SMTP server is set"`
+}
+
+type Jobs struct {
+	DigestHour int `key:"digest_hour" env:"DIGEST_HOUR" usage:"local hour at which the arrivals digest for the next day is sent"`
+}
+
+type Log struct {
+	Level  string `key:"level" env:"LOG_LEVEL" usage:"minimum level logged: debug, info, warn or error"`
+	Format string `key:"format" env:"LOG_FORMAT" usage:"log format: text or json"`
+}
+
+type Metrics struct {
+	Token string `key:"token" env:"METRICS_TOKEN" usage:"bearer token required to read /metrics, open when empty" secret:"true"`
+}
+
+// Default returns the configuration used for settings that aren't set anywhere else
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:              ":8080",
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       60 * time.Second,
+			WriteTimeout:      60 * time.Second,
+			IdleTimeout:       120 * time.Second,
+			ShutdownTimeout:   20 * time.Second,
+			IdempotencyKeyTTL: 24 * time.Hour,
+		},
+		Database: Database{
+			Path: "./bookings.db",
+		},
+		Storage: Storage{
+			AttachmentsDir: "./attachments",
+		},
+		Auth: Auth{
+			SessionDuration:   24 * time.Hour,
+			GroupCodeDuration: 24 * time.Hour,
+			BcryptCost:        14, // Good security while maintaining reasonable performance
+		},
+		CORS: CORS{
+			AllowedOrigins: []string{
+				"http://localhost:5173", // Vite dev server
+				"http://localhost:3000", // Alternative dev port
+			},
+			MaxAge: 10 * time.Minute,
+		},
+		Mail: Mail{
+			From: "Booker <no-reply@localhost>",
+		},
+		Jobs: Jobs{
+			DigestHour: 18,
+		},
+		Log: Log{
+			Level:  "info",
+			Format: "text",
+		},
+	}
+}
+
+// Validate reports every invalid setting
+func (c Config) Validate() error {
+	var errs []error
+	invalid := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: "+format, append([]any{key}, args...)...))
+	}
+
+	if c.Server.Addr == "" {
+		invalid("server.addr", "must not be empty")
+	}
+	for _, t := range []struct {
+		key string
+		d   time.Duration
+	}{
+		{"server.read_header_timeout", c.Server.ReadHeaderTimeout},
+		{"server.read_timeout", c.Server.ReadTimeout},
+		{"server.write_timeout", c.Server.WriteTimeout},
+		{"server.idle_timeout", c.Server.IdleTimeout},
+		{"server.shutdown_timeout", c.Server.ShutdownTimeout},
+	} {
+		if t.d <= 0 {
+			invalid(t.key, "must be positive")
+		}
+	}
+
+	if c.Server.IdempotencyKeyTTL < time.Minute {
+		invalid("server.idempotency_key_ttl", "must be at least 1m")
+	}
+
+	if c.Database.Path == "" {
+		invalid("database.path", "must not be empty")
+	}
+	if c.Storage.AttachmentsDir == "" {
+		invalid("storage.attachments_dir", "must not be empty")
+	}
+
+	if c.Auth.SessionDuration < time.Minute {
+		invalid("auth.session_duration", "must be at least 1m")
+	}
+	if c.Auth.GroupCodeDuration < time.Minute {
+		invalid("auth.group_code_duration", "must be at least 1m")
+	}
+	if c.Auth.BcryptCost < bcrypt.MinCost || c.Auth.BcryptCost > bcrypt.MaxCost {
+		invalid("auth.bcrypt_cost", "must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	for _, origin := range c.AllowedOrigins() {
+		if err := cors.ValidateOrigin(origin); err != nil {
+			invalid("cors", "%v", err)
+		}
+	}
+	if c.CORS.MaxAge < 0 {
+		invalid("cors.max_age", "must not be negative")
+	}
+
+	if _, err := mail.ParseAddress(c.Mail.From); err != nil {
+		invalid("mail.from", "invalid address %q", c.Mail.From)
+	}
+	if c.Mail.SMTPAddr != "" {
+		if _, _, err := net.SplitHostPort(c.Mail.SMTPAddr); err != nil {
+			invalid("mail.smtp_addr", "expected host:port, got %q", c.Mail.SMTPAddr)
+		}
+	}
+
+	if c.Jobs.DigestHour < 0 || c.Jobs.DigestHour > 23 {
+		invalid("jobs.digest_hour", "must be between 0 and 23")
+	}
+
+	if _, err := logging.New(io.Discard, c.Log.Level, c.Log.Format); err != nil {
+		invalid("log", "%v", err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// AllowedOrigins returns the origins allowed by CORS, including the frontend URL
+func (c Config) AllowedOrigins() []string {
+	origins := append([]string(nil), c.CORS.AllowedOrigins...)
+	if c.CORS.FrontendURL != "" {
+		origins = append(origins, strings.TrimRight(c.CORS.FrontendURL, "/"))
+	}
+	return origins
+}
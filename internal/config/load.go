@@ -0,0 +1,246 @@
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// setting is one field of Config together with its names
+type setting struct {
+	section string
+	key     string // "section.name", also the flag name
+	env     string
+	usage   string
+	secret  bool
+	value   reflect.Value
+}
+
+// Load builds the configuration from, in increasing order of precedence, the defaults,
+// a config file, environment variables and command-line flags, then validates it.
+//
+// The config file is named by the -config flag or CONFIG_FILE and may be TOML or JSON,
+// with one table per section. Flags are named after the keys, as in -server.addr=:9090.
+// Empty environment variables are ignored.
+func Load(args []string) (Config, error) {
+	cfg := Default()
+	cfg.sources = make(map[string]string)
+	settings := cfg.settings()
+
+	fs := flag.NewFlagSet("booker", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "config file, .toml or .json (env CONFIG_FILE)")
+	flagValues := make(map[string]*string)
+	for _, s := range settings {
+		flagValues[s.key] = fs.String(s.key, "", fmt.Sprintf("%s (env %s)", s.usage, s.env))
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+	if fs.NArg() > 0 {
+		return cfg, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	if *configFile != "" {
+		values, err := readFile(*configFile)
+		if err != nil {
+			return cfg, err
+		}
+		for _, s := range settings {
+			if v, ok := values[s.key]; ok {
+				if err := cfg.set(s, v, "file "+*configFile); err != nil {
+					return cfg, err
+				}
+				delete(values, s.key)
+			}
+		}
+		if len(values) > 0 {
+			unknown := make([]string, 0, len(values))
+			for key := range values {
+				unknown = append(unknown, key)
+			}
+			slices.Sort(unknown)
+			return cfg, fmt.Errorf("%s: unknown settings %s", *configFile, strings.Join(unknown, ", "))
+		}
+	}
+
+	for _, s := range settings {
+		if v := os.Getenv(s.env); v != "" {
+			if err := cfg.set(s, v, "env "+s.env); err != nil {
+				return cfg, err
+			}
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.key == f.Name && flagErr == nil {
+				flagErr = cfg.set(s, *flagValues[s.key], "flag -"+s.key)
+			}
+		}
+	})
+	if flagErr != nil {
+		return cfg, flagErr
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Print writes the effective configuration in TOML, noting where each value that
+// isn't a default came from. Secrets are redacted.
+func (c Config) Print(w io.Writer) {
+	section := ""
+	for _, s := range c.settings() {
+		if s.section != section {
+			if section != "" {
+				fmt.Fprintln(w)
+			}
+			section = s.section
+			fmt.Fprintf(w, "[%s]\n", section)
+		}
+
+		line := fmt.Sprintf("%s = %s", strings.TrimPrefix(s.key, s.section+"."), formatValue(s))
+		if source, ok := c.sources[s.key]; ok {
+			line = fmt.Sprintf("%-50s # %s", line, source)
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// settings lists the fields of the config in declaration order
+func (c *Config) settings() []setting {
+	var settings []setting
+	root := reflect.ValueOf(c).Elem()
+	for i := range root.NumField() {
+		sectionField := root.Type().Field(i)
+		section := sectionField.Tag.Get("key")
+		if section == "" {
+			continue
+		}
+		for j := range sectionField.Type.NumField() {
+			f := sectionField.Type.Field(j)
+			settings = append(settings, setting{
+				section: section,
+				key:     section + "." + f.Tag.Get("key"),
+				env:     f.Tag.Get("env"),
+				usage:   f.Tag.Get("usage"),
+				secret:  f.Tag.Get("secret") == "true",
+				value:   root.Field(i).Field(j),
+			})
+		}
+	}
+	return settings
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// set parses a value into a setting and records its source
+func (c *Config) set(s setting, value, source string) error {
+	switch {
+	case s.value.Type() == durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s from %s: invalid duration %q", s.key, source, value)
+		}
+		s.value.SetInt(int64(d))
+	case s.value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s from %s: invalid number %q", s.key, source, value)
+		}
+		s.value.SetInt(int64(n))
+	case s.value.Kind() == reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		s.value.Set(reflect.ValueOf(items))
+	default:
+		s.value.SetString(value)
+	}
+	c.sources[s.key] = source
+	return nil
+}
+
+func formatValue(s setting) string {
+	switch v := s.value.Interface().(type) {
+	case time.Duration:
+		return strconv.Quote(v.String())
+	case int:
+		return strconv.Itoa(v)
+	case []string:
+		quoted := make([]string, len(v))
+		for i, item := range v {
+			quoted[i] = strconv.Quote(item)
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	case string:
+		if s.secret && v != "" {
+			return `"<redacted>"`
+		}
+		return strconv.Quote(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// readFile reads a config file into "section.key" values, in the same string
+// form as environment variables
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var tree map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	case ".json":
+		err = json.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("%s: unsupported config file format, use .toml or .json", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	for section, v := range tree {
+		table, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s: %s must be a table of settings", path, section)
+		}
+		for key, v := range table {
+			values[section+"."+key] = fileValue(v)
+		}
+	}
+	return values, nil
+}
+
+func fileValue(v any) string {
+	switch v := v.(type) {
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fileValue(item)
+		}
+		return strings.Join(items, ",")
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,199 @@
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Policy describes which cross-origin requests the browser may make
+type Policy struct {
+	// AllowedOrigins lists origins as scheme://host[:port]. A pattern such as
+	// https://*.example.com matches every subdomain of example.com, and "*" matches
+	// any origin, in which case credentials are never allowed.
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration // How long browsers may cache a preflight response
+}
+
+// Rule applies a policy to the requests whose path starts with PathPrefix
+type Rule struct {
+	PathPrefix string
+	Policy     Policy
+}
+
+// compiled is a policy with its origins parsed and headers joined once
+type compiled struct {
+	anyOrigin        bool
+	origins          map[string]bool
+	suffixes         []wildcard
+	methods          string
+	headers          string
+	exposed          string
+	allowCredentials bool
+	maxAge           string
+}
+
+// wildcard matches the subdomains of a host for one scheme
+type wildcard struct {
+	scheme string
+	suffix string // ".example.com", with the port if any
+}
+
+// CORS answers preflight requests and adds the CORS headers to responses
+type CORS struct {
+	defaultPolicy compiled
+	rules         []Rule
+	overrides     []compiled
+}
+
+// New creates the middleware state for a default policy and per-path overrides.
+// The override with the longest matching prefix wins.
+func New(defaultPolicy Policy, overrides ...Rule) (*CORS, error) {
+	c := &CORS{rules: overrides}
+	var err error
+	if c.defaultPolicy, err = compile(defaultPolicy); err != nil {
+		return nil, err
+	}
+	for _, r := range overrides {
+		p, err := compile(r.Policy)
+		if err != nil {
+			return nil, fmt.Errorf("CORS policy for %s: %w", r.PathPrefix, err)
+		}
+		c.overrides = append(c.overrides, p)
+	}
+	return c, nil
+}
+
+// ValidateOrigin checks an allowed origin or origin pattern
+func ValidateOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.TrimRight(u.Path, "/") != "" || u.RawQuery != "" {
+		return fmt.Errorf("invalid origin %q, expected scheme://host[:port]", origin)
+	}
+	if strings.Contains(strings.TrimPrefix(u.Host, "*."), "*") {
+		return fmt.Errorf("invalid origin %q, a wildcard is only allowed as the first label", origin)
+	}
+	return nil
+}
+
+// Handler returns the gin middleware. Preflight requests are answered right away.
+func (c *CORS) Handler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		p := c.policyFor(ctx.Request.URL.Path)
+		header := ctx.Writer.Header()
+		header.Add("Vary", "Origin")
+
+		origin := ctx.Request.Header.Get("Origin")
+		allowed := origin != "" && p.allows(origin)
+		if allowed {
+			if p.anyOrigin {
+				header.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				header.Set("Access-Control-Allow-Origin", origin)
+			}
+			if p.allowCredentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+
+		if ctx.Request.Method == http.MethodOptions {
+			if allowed {
+				header.Set("Access-Control-Allow-Methods", p.methods)
+				header.Set("Access-Control-Allow-Headers", p.headers)
+				if p.maxAge != "" {
+					header.Set("Access-Control-Max-Age", p.maxAge)
+				}
+			}
+			ctx.AbortWithStatus(204) // No Content
+			return
+		}
+
+		if allowed && p.exposed != "" {
+			header.Set("Access-Control-Expose-Headers", p.exposed)
+		}
+		ctx.Next()
+	}
+}
+
+// AllowsOrigin reports whether the policy for a path lets a page from origin make
+// requests, for requests such as WebSocket handshakes that browsers don't check
+func (c *CORS) AllowsOrigin(path, origin string) bool {
+	return c.policyFor(path).allows(origin)
+}
+
+func (c *CORS) policyFor(path string) compiled {
+	best := -1
+	for i, r := range c.rules {
+		if strings.HasPrefix(path, r.PathPrefix) && (best < 0 || len(r.PathPrefix) > len(c.rules[best].PathPrefix)) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return c.defaultPolicy
+	}
+	return c.overrides[best]
+}
+
+func compile(p Policy) (compiled, error) {
+	out := compiled{
+		origins:          make(map[string]bool),
+		methods:          strings.Join(p.AllowedMethods, ", "),
+		headers:          strings.Join(p.AllowedHeaders, ", "),
+		exposed:          strings.Join(p.ExposedHeaders, ", "),
+		allowCredentials: p.AllowCredentials,
+	}
+	if p.MaxAge > 0 {
+		out.maxAge = strconv.Itoa(int(p.MaxAge.Seconds()))
+	}
+
+	for _, origin := range p.AllowedOrigins {
+		if err := ValidateOrigin(origin); err != nil {
+			return compiled{}, err
+		}
+		origin = strings.ToLower(strings.TrimRight(origin, "/"))
+		if origin == "*" {
+			out.anyOrigin = true
+			// Browsers reject credentials for a wildcard origin
+			out.allowCredentials = false
+			continue
+		}
+		if scheme, host, ok := strings.Cut(origin, "://*."); ok {
+			out.suffixes = append(out.suffixes, wildcard{scheme: scheme, suffix: "." + host})
+			continue
+		}
+		out.origins[origin] = true
+	}
+	return out, nil
+}
+
+func (p compiled) allows(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if p.origins[origin] {
+		return true
+	}
+	scheme, host, ok := strings.Cut(origin, "://")
+	if !ok {
+		return false
+	}
+	for _, w := range p.suffixes {
+		if scheme == w.scheme && len(host) > len(w.suffix) && strings.HasSuffix(host, w.suffix) {
+			return true
+		}
+	}
+	return false
+}
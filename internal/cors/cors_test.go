@@ -0,0 +1,198 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		name    string
+		origins []string
+		origin  string
+		want    bool
+	}{
+		{"exact", []string{"https://app.example.com"}, "https://app.example.com", true},
+		{"exact ignores case", []string{"https://App.Example.com"}, "https://app.EXAMPLE.com", true},
+		{"exact with trailing slash", []string{"https://app.example.com/"}, "https://app.example.com", true},
+		{"exact other host", []string{"https://app.example.com"}, "https://evil.example.com", false},
+		{"exact other scheme", []string{"https://app.example.com"}, "http://app.example.com", false},
+		{"exact other port", []string{"http://localhost:3000"}, "http://localhost:4000", false},
+		{"wildcard subdomain", []string{"https://*.example.com"}, "https://app.example.com", true},
+		{"wildcard nested subdomain", []string{"https://*.example.com"}, "https://a.b.example.com", true},
+		{"wildcard bare domain", []string{"https://*.example.com"}, "https://example.com", false},
+		{"wildcard other scheme", []string{"https://*.example.com"}, "http://app.example.com", false},
+		{"wildcard lookalike domain", []string{"https://*.example.com"}, "https://app.evilexample.com", false},
+		{"wildcard suffix attack", []string{"https://*.example.com"}, "https://app.example.com.evil.com", false},
+		{"wildcard with port", []string{"http://*.example.com:8080"}, "http://app.example.com:8080", true},
+		{"wildcard missing port", []string{"http://*.example.com:8080"}, "http://app.example.com", false},
+		{"any origin", []string{"*"}, "https://anything.test", true},
+		{"no origins", nil, "https://app.example.com", false},
+		{"not an origin", []string{"https://*.example.com"}, "app.example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := compile(Policy{AllowedOrigins: tt.origins})
+			if err != nil {
+				t.Fatalf("compile(%q): %v", tt.origins, err)
+			}
+			if got := p.allows(tt.origin); got != tt.want {
+				t.Errorf("allows(%q) with %q = %v, want %v", tt.origin, tt.origins, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnyOriginDropsCredentials(t *testing.T) {
+	p, err := compile(Policy{AllowedOrigins: []string{"https://app.example.com", "*"}, AllowCredentials: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.anyOrigin || p.allowCredentials {
+		t.Errorf("anyOrigin = %v, allowCredentials = %v, want true, false", p.anyOrigin, p.allowCredentials)
+	}
+
+	rec := serve(t, Policy{AllowedOrigins: []string{"*"}, AllowCredentials: true}, nil, http.MethodGet, "/bookings", "https://app.example.com")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want *", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Access-Control-Allow-Credentials = %q, want none", got)
+	}
+}
+
+func TestPolicyForLongestPrefix(t *testing.T) {
+	c, err := New(Policy{ExposedHeaders: []string{"Default"}},
+		Rule{PathPrefix: "/a/", Policy: Policy{ExposedHeaders: []string{"A"}}},
+		Rule{PathPrefix: "/a/b/", Policy: Policy{ExposedHeaders: []string{"AB"}}},
+		Rule{PathPrefix: "/c/", Policy: Policy{ExposedHeaders: []string{"C"}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/a/x", "A"},
+		{"/a/b/x", "AB"},
+		{"/a/bx", "A"},
+		{"/c/", "C"},
+		{"/d", "Default"},
+		{"/", "Default"},
+	}
+	for _, tt := range tests {
+		if got := c.policyFor(tt.path).exposed; got != tt.want {
+			t.Errorf("policyFor(%q) exposes %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	policy := Policy{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+
+	handled := false
+	rec := serve(t, policy, &handled, http.MethodOptions, "/bookings", "https://app.example.com")
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want 204", rec.Code)
+	}
+	if handled {
+		t.Error("preflight reached the handler")
+	}
+	want := map[string]string{
+		"Access-Control-Allow-Origin":      "https://app.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Methods":     "GET, POST",
+		"Access-Control-Allow-Headers":     "Content-Type, Authorization",
+		"Access-Control-Max-Age":           "600",
+		"Vary":                             "Origin",
+	}
+	for name, value := range want {
+		if got := rec.Header().Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+
+	// A preflight from a foreign origin is answered without allowing anything
+	rec = serve(t, policy, nil, http.MethodOptions, "/bookings", "https://evil.example.com")
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want 204", rec.Code)
+	}
+	for _, name := range []string{"Access-Control-Allow-Origin", "Access-Control-Allow-Methods", "Access-Control-Max-Age"} {
+		if got := rec.Header().Get(name); got != "" {
+			t.Errorf("%s = %q for a foreign origin, want none", name, got)
+		}
+	}
+}
+
+func TestValidateOrigin(t *testing.T) {
+	valid := []string{
+		"*",
+		"https://app.example.com",
+		"http://localhost:3000",
+		"https://*.example.com",
+		"https://app.example.com/",
+	}
+	for _, origin := range valid {
+		if err := ValidateOrigin(origin); err != nil {
+			t.Errorf("ValidateOrigin(%q) = %v, want nil", origin, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"app.example.com",
+		"ftp://app.example.com",
+		"https://",
+		"https://app.example.com/path",
+		"https://app.example.com?x=1",
+		"https://app.*.example.com",
+		"https://*.*.example.com",
+		"https://*example.com",
+	}
+	for _, origin := range invalid {
+		if err := ValidateOrigin(origin); err == nil {
+			t.Errorf("ValidateOrigin(%q) = nil, want an error", origin)
+		}
+	}
+
+	if _, err := New(Policy{AllowedOrigins: []string{"app.example.com"}}); err == nil {
+		t.Error("New accepted an invalid origin")
+	}
+}
+
+// serve sends one request through the middleware and records whether the handler ran
+func serve(t *testing.T, policy Policy, handled *bool, method, path, origin string) *httptest.ResponseRecorder {
+	t.Helper()
+	c, err := New(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(c.Handler())
+	router.Any("/*path", func(ctx *gin.Context) {
+		if handled != nil {
+			*handled = true
+		}
+		ctx.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Origin", origin)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
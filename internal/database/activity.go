@@ -0,0 +1,85 @@
+package database
+
+import (
+	"database/sql"
+)
+
+func CreateActivityTable(db *sql.DB) error {
+	sqlStmt := `
+	create table if not exists activity (
+		id string not null primary key,
+		created_at string,
+		group_id string not null,
+		user_id string not null,
+		action string not null,
+		entity_type string not null,
+		entity_id string not null,
+		summary string default ''
+	);
+	`
+
+	_, err := db.Exec(sqlStmt)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`create index if not exists activity_group_created on activity (group_id, created_at);`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Service) GetActivityTableName() string {
+	return s.activityTable
+}
+
+// GetActivityByGroupID returns the most recent activity of a group, newest first
+func (s *Service) GetActivityByGroupID(groupID string, limit int) ([]Activity, error) {
+	defer s.lock("GetActivityByGroupID")()
+
+	rows, err := s.db.Query("SELECT * FROM "+s.activityTable+" WHERE group_id = ? ORDER BY created_at DESC, rowid DESC LIMIT ?", groupID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []Activity
+	for rows.Next() {
+		var result Activity
+		if err := rows.Scan(
+			&result.ID,
+			&result.CreatedAt,
+			&result.GroupID,
+			&result.UserID,
+			&result.Action,
+			&result.EntityType,
+			&result.EntityID,
+			&result.Summary); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func (s *Service) InsertActivity(result Activity) error {
+	defer s.lock("InsertActivity")()
+	_, err := s.db.Exec("INSERT INTO "+s.activityTable+
+		" (id, created_at, group_id, user_id, action, entity_type, entity_id, summary) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		result.ID,
+		result.CreatedAt,
+		result.GroupID,
+		result.UserID,
+		result.Action,
+		result.EntityType,
+		result.EntityID,
+		result.Summary)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
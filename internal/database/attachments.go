@@ -0,0 +1,133 @@
+package database
+
+import (
+	"database/sql"
+)
+
+func CreateAttachmentsTable(db *sql.DB) error {
+	sqlStmt := `
+	create table if not exists attachments (
+		id string not null primary key,
+		created_at string,
+		created_by string,
+		group_id string not null,
+		parent_type string not null,
+		parent_id string not null,
+		filename string not null,
+		content_type string not null,
+		size integer default 0,
+		storage_key string not null
+	);
+	`
+
+	_, err := db.Exec(sqlStmt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Service) GetAttachmentsTableName() string {
+	return s.attachmentsTable
+}
+
+func (s *Service) GetAttachmentByID(id string) (Attachment, error) {
+	defer s.lock("GetAttachmentByID")()
+	var result Attachment
+	err := s.db.QueryRow("SELECT * FROM "+s.attachmentsTable+" WHERE id = ?", id).Scan(
+		&result.ID,
+		&result.CreatedAt,
+		&result.CreatedBy,
+		&result.GroupID,
+		&result.ParentType,
+		&result.ParentID,
+		&result.Filename,
+		&result.ContentType,
+		&result.Size,
+		&result.StorageKey)
+	if err != nil {
+		return Attachment{}, err
+	}
+	return result, nil
+}
+
+func (s *Service) GetAttachmentsByParent(parentType, parentID string) ([]Attachment, error) {
+	defer s.lock("GetAttachmentsByParent")()
+
+	rows, err := s.db.Query("SELECT * FROM "+s.attachmentsTable+" WHERE parent_type = ? AND parent_id = ? ORDER BY created_at", parentType, parentID)
+	if err != nil {
+		return nil, err
+	}
+	return scanAttachments(rows)
+}
+
+// deleteAttachments removes the records of the attachments matching condition within tx
+// and returns them, so their blobs can be removed once tx is committed
+func (s *Service) deleteAttachments(tx *sql.Tx, condition string, args ...any) ([]Attachment, error) {
+	rows, err := tx.Query("SELECT * FROM "+s.attachmentsTable+" WHERE "+condition, args...)
+	if err != nil {
+		return nil, err
+	}
+	attachments, err := scanAttachments(rows)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM "+s.attachmentsTable+" WHERE "+condition, args...); err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+func scanAttachments(rows *sql.Rows) ([]Attachment, error) {
+	defer rows.Close()
+	var results []Attachment
+	for rows.Next() {
+		var result Attachment
+		if err := rows.Scan(
+			&result.ID,
+			&result.CreatedAt,
+			&result.CreatedBy,
+			&result.GroupID,
+			&result.ParentType,
+			&result.ParentID,
+			&result.Filename,
+			&result.ContentType,
+			&result.Size,
+			&result.StorageKey); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+func (s *Service) InsertAttachment(result Attachment) error {
+	defer s.lock("InsertAttachment")()
+	_, err := s.db.Exec("INSERT INTO "+s.attachmentsTable+
+		" (id, created_at, created_by, group_id, parent_type, parent_id, filename, content_type, size, storage_key) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		result.ID,
+		result.CreatedAt,
+		result.CreatedBy,
+		result.GroupID,
+		result.ParentType,
+		result.ParentID,
+		result.Filename,
+		result.ContentType,
+		result.Size,
+		result.StorageKey)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Service) DeleteAttachment(id string) error {
+	defer s.lock("DeleteAttachment")()
+	_, err := s.db.Exec("DELETE FROM "+s.attachmentsTable+" WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	return nil
+}
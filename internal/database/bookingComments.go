@@ -0,0 +1,131 @@
+package database
+
+import (
+	"database/sql"
+)
+
+func CreateBookingCommentsTable(db *sql.DB) error {
+	sqlStmt := `
+	create table if not exists booking_comments (
+		id string not null primary key,
+		created_at string,
+		updated_at string default '',
+		booking_id string not null,
+		parent_id string default '',
+		author_id string not null,
+		body string not null,
+		deleted integer default 0
+	);
+	`
+
+	_, err := db.Exec(sqlStmt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Service) GetBookingCommentsTableName() string {
+	return s.bookingCommentsTable
+}
+
+func (s *Service) GetBookingCommentByID(id string) (BookingComment, error) {
+	defer s.lock("GetBookingCommentByID")()
+	var result BookingComment
+	err := s.db.QueryRow("SELECT c.id, c.created_at, c.updated_at, c.booking_id, c.parent_id, c.author_id, coalesce(u.username, ''), c.body, c.deleted FROM "+
+		s.bookingCommentsTable+" c LEFT JOIN "+s.usersTable+" u ON u.id = c.author_id WHERE c.id = ?", id).Scan(
+		&result.ID,
+		&result.CreatedAt,
+		&result.UpdatedAt,
+		&result.BookingID,
+		&result.ParentID,
+		&result.AuthorID,
+		&result.AuthorName,
+		&result.Body,
+		&result.Deleted)
+	if err != nil {
+		return BookingComment{}, err
+	}
+	return result, nil
+}
+
+// GetBookingCommentsByBookingID returns the comments of a booking oldest first,
+// with the author's username filled in
+func (s *Service) GetBookingCommentsByBookingID(bookingID string) ([]BookingComment, error) {
+	defer s.lock("GetBookingCommentsByBookingID")()
+
+	rows, err := s.db.Query("SELECT c.id, c.created_at, c.updated_at, c.booking_id, c.parent_id, c.author_id, coalesce(u.username, ''), c.body, c.deleted FROM "+
+		s.bookingCommentsTable+" c LEFT JOIN "+s.usersTable+" u ON u.id = c.author_id WHERE c.booking_id = ? ORDER BY c.created_at, c.rowid", bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []BookingComment
+	for rows.Next() {
+		var result BookingComment
+		if err := rows.Scan(
+			&result.ID,
+			&result.CreatedAt,
+			&result.UpdatedAt,
+			&result.BookingID,
+			&result.ParentID,
+			&result.AuthorID,
+			&result.AuthorName,
+			&result.Body,
+			&result.Deleted); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func (s *Service) InsertBookingComment(result BookingComment) error {
+	defer s.lock("InsertBookingComment")()
+	_, err := s.db.Exec("INSERT INTO "+s.bookingCommentsTable+
+		" (id, created_at, updated_at, booking_id, parent_id, author_id, body) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		result.ID,
+		result.CreatedAt,
+		result.UpdatedAt,
+		result.BookingID,
+		result.ParentID,
+		result.AuthorID,
+		result.Body)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Service) UpdateBookingComment(result BookingComment) error {
+	defer s.lock("UpdateBookingComment")()
+	_, err := s.db.Exec("UPDATE "+s.bookingCommentsTable+" SET body = ?, updated_at = ? WHERE id = ?",
+		result.Body,
+		result.UpdatedAt,
+		result.ID)
+	return err
+}
+
+// DeleteBookingComment removes a comment. A comment that has replies is kept
+// as a deleted placeholder with its body cleared so the thread stays intact.
+func (s *Service) DeleteBookingComment(id string, deletedAt string) error {
+	defer s.lock("DeleteBookingComment")()
+
+	var replies int
+	err := s.db.QueryRow("SELECT count(*) FROM "+s.bookingCommentsTable+" WHERE parent_id = ?", id).Scan(&replies)
+	if err != nil {
+		return err
+	}
+
+	if replies > 0 {
+		_, err = s.db.Exec("UPDATE "+s.bookingCommentsTable+" SET body = '', deleted = 1, updated_at = ? WHERE id = ?", deletedAt, id)
+		return err
+	}
+
+	_, err = s.db.Exec("DELETE FROM "+s.bookingCommentsTable+" WHERE id = ?", id)
+	return err
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"database/sql"
+	"strconv"
 	"strings"
 )
 
@@ -18,7 +19,10 @@ func CreateBookingsTable(db *sql.DB) error {
 		end_date string,
 		guest_name string,
 		adults integer default 0,
-		children integer default 0
+		children integer default 0,
+		guest_id string default '',
+		notes string default '',
+		version integer not null default 1
 	);
 	`
 
@@ -38,6 +42,30 @@ func CreateBookingsTable(db *sql.DB) error {
 		return err
 	}
 
+	// Migration: Link bookings to an entry in the guest directory
+	_, err = db.Exec(`ALTER TABLE bookings ADD COLUMN guest_id string DEFAULT '';`)
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add internal notes
+	_, err = db.Exec(`ALTER TABLE bookings ADD COLUMN notes string DEFAULT '';`)
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add the version for optimistic concurrency control
+	_, err = db.Exec(`ALTER TABLE bookings ADD COLUMN version integer NOT NULL DEFAULT 1;`)
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Listings filter on the property and page through the start dates
+	_, err = db.Exec(`create index if not exists bookings_property_start on bookings (property_id, start_date);`)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -46,8 +74,7 @@ func (s *Service) GetBookingsTableName() string {
 }
 
 func (s *Service) GetAllBookings() ([]Booking, error) {
-	s.m.Lock()
-	defer s.m.Unlock()
+	defer s.lock("GetAllBookings")()
 	rows, err := s.db.Query("SELECT * FROM " + s.bookingsTable)
 	if err != nil {
 		return nil, err
@@ -66,7 +93,10 @@ func (s *Service) GetAllBookings() ([]Booking, error) {
 			&result.EndDate,
 			&result.GuestName,
 			&result.Adults,
-			&result.Children); err != nil {
+			&result.Children,
+			&result.GuestID,
+			&result.Notes,
+			&result.Version); err != nil {
 			return nil, err
 		}
 		results = append(results, result)
@@ -76,8 +106,7 @@ func (s *Service) GetAllBookings() ([]Booking, error) {
 }
 
 func (s *Service) GetBookingByID(id string) (Booking, error) {
-	s.m.Lock()
-	defer s.m.Unlock()
+	defer s.lock("GetBookingByID")()
 	var result Booking
 	err := s.db.QueryRow("SELECT * FROM "+s.bookingsTable+" WHERE id = ?", id).Scan(
 		&result.ID,
@@ -88,7 +117,10 @@ func (s *Service) GetBookingByID(id string) (Booking, error) {
 		&result.EndDate,
 		&result.GuestName,
 		&result.Adults,
-		&result.Children)
+		&result.Children,
+		&result.GuestID,
+		&result.Notes,
+		&result.Version)
 	if err != nil {
 		return Booking{}, err
 	}
@@ -96,8 +128,7 @@ func (s *Service) GetBookingByID(id string) (Booking, error) {
 }
 
 func (s *Service) GetBookingsByPropertyID(propertyID string) ([]Booking, error) {
-	s.m.Lock()
-	defer s.m.Unlock()
+	defer s.lock("GetBookingsByPropertyID")()
 	rows, err := s.db.Query("SELECT * FROM "+s.bookingsTable+" WHERE property_id = ?", propertyID)
 	if err != nil {
 		return nil, err
@@ -115,7 +146,10 @@ func (s *Service) GetBookingsByPropertyID(propertyID string) ([]Booking, error)
 			&result.EndDate,
 			&result.GuestName,
 			&result.Adults,
-			&result.Children); err != nil {
+			&result.Children,
+			&result.GuestID,
+			&result.Notes,
+			&result.Version); err != nil {
 			return nil, err
 		}
 		results = append(results, result)
@@ -124,8 +158,7 @@ func (s *Service) GetBookingsByPropertyID(propertyID string) ([]Booking, error)
 }
 
 func (s *Service) GetBookingsByPropertyIds(propertyIDs []string) ([]Booking, error) {
-	s.m.Lock()
-	defer s.m.Unlock()
+	defer s.lock("GetBookingsByPropertyIds")()
 
 	query := "SELECT * FROM " + s.bookingsTable + " WHERE property_id IN (?" + strings.Repeat(",?", len(propertyIDs)-1) + ")"
 	args := make([]interface{}, len(propertyIDs))
@@ -149,7 +182,238 @@ func (s *Service) GetBookingsByPropertyIds(propertyIDs []string) ([]Booking, err
 			&result.EndDate,
 			&result.GuestName,
 			&result.Adults,
-			&result.Children); err != nil {
+			&result.Children,
+			&result.GuestID,
+			&result.Notes,
+			&result.Version); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (s *Service) GetBookingsByGuestID(guestID string) ([]Booking, error) {
+	defer s.lock("GetBookingsByGuestID")()
+	rows, err := s.db.Query("SELECT * FROM "+s.bookingsTable+" WHERE guest_id = ? ORDER BY start_date", guestID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var results []Booking
+	for rows.Next() {
+		var result Booking
+		if err := rows.Scan(
+			&result.ID,
+			&result.CreatedAt,
+			&result.CreatedBy,
+			&result.PropertyID,
+			&result.StartDate,
+			&result.EndDate,
+			&result.GuestName,
+			&result.Adults,
+			&result.Children,
+			&result.GuestID,
+			&result.Notes,
+			&result.Version); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// GetBookingsByPropertyIdsInRange returns the bookings of the given properties
+// that have at least one night between from (inclusive) and to (exclusive).
+// Dates are compared as YYYY-MM-DD strings.
+func (s *Service) GetBookingsByPropertyIdsInRange(propertyIDs []string, from, to string) ([]Booking, error) {
+	defer s.lock("GetBookingsByPropertyIdsInRange")()
+	if len(propertyIDs) == 0 {
+		return nil, nil
+	}
+
+	query := "SELECT * FROM " + s.bookingsTable + " WHERE property_id IN (?" + strings.Repeat(",?", len(propertyIDs)-1) + ")" +
+		" AND start_date < ? AND end_date > ?"
+	args := make([]any, 0, len(propertyIDs)+2)
+	for _, id := range propertyIDs {
+		args = append(args, id)
+	}
+	args = append(args, to, from)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var results []Booking
+	for rows.Next() {
+		var result Booking
+		if err := rows.Scan(
+			&result.ID,
+			&result.CreatedAt,
+			&result.CreatedBy,
+			&result.PropertyID,
+			&result.StartDate,
+			&result.EndDate,
+			&result.GuestName,
+			&result.Adults,
+			&result.Children,
+			&result.GuestID,
+			&result.Notes,
+			&result.Version); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// BookingFilter selects the bookings returned by ListBookings and their order.
+// Empty fields don't filter.
+type BookingFilter struct {
+	GroupID    string
+	PropertyID string
+	From       string // Bookings with a night on or after this date, YYYY-MM-DD
+	To         string // Bookings with a night before this date
+	GuestName  string // Part of the guest name, ignoring case
+	Status     string // One of the BookingStatus values, relative to Today
+	Today      string
+	Sort       string // One of the BookingSort values, BookingSortStartDate when empty
+	Descending bool
+	After      *BookingCursor // Only the bookings after this one, in the same order
+	Limit      int            // At most this many bookings, all of them when zero
+}
+
+// BookingCursor is the position of a booking in a listing: the value it is sorted by, then its ID
+type BookingCursor struct {
+	Key string
+	ID  string
+}
+
+// CursorFor returns the position of b in the listings of f
+func (f BookingFilter) CursorFor(b Booking) BookingCursor {
+	if f.Sort == BookingSortCreatedAt {
+		return BookingCursor{Key: b.CreatedAt, ID: b.ID}
+	}
+	return BookingCursor{Key: b.StartDate, ID: b.ID}
+}
+
+// ListBookings returns the bookings selected by f. Listings go on with the bookings
+// after the last one returned, so they page through in a stable order even while
+// bookings are added.
+func (s *Service) ListBookings(f BookingFilter) ([]Booking, error) {
+	defer s.lock("ListBookings")()
+
+	var where []string
+	var args []any
+	if f.GroupID != "" {
+		where = append(where, "property_id IN (SELECT id FROM "+s.propertyTable+" WHERE group_id = ?)")
+		args = append(args, f.GroupID)
+	}
+	if f.PropertyID != "" {
+		where = append(where, "property_id = ?")
+		args = append(args, f.PropertyID)
+	}
+	if f.From != "" {
+		where = append(where, "end_date > ?")
+		args = append(args, f.From)
+	}
+	if f.To != "" {
+		where = append(where, "start_date < ?")
+		args = append(args, f.To)
+	}
+	if f.GuestName != "" {
+		where = append(where, "lower(guest_name) LIKE ? ESCAPE '\\'")
+		args = append(args, containsPattern(strings.ToLower(f.GuestName)))
+	}
+	switch f.Status {
+	case BookingStatusUpcoming:
+		where = append(where, "start_date > ?")
+		args = append(args, f.Today)
+	case BookingStatusCurrent:
+		where = append(where, "start_date <= ? AND end_date > ?")
+		args = append(args, f.Today, f.Today)
+	case BookingStatusPast:
+		where = append(where, "end_date <= ?")
+		args = append(args, f.Today)
+	}
+
+	// Creation times are unix seconds, stored as text
+	key, keyParam := "start_date", "?"
+	if f.Sort == BookingSortCreatedAt {
+		key, keyParam = "CAST(created_at AS INTEGER)", "CAST(? AS INTEGER)"
+	}
+	order, after := "ASC", ">"
+	if f.Descending {
+		order, after = "DESC", "<"
+	}
+	if f.After != nil {
+		where = append(where, "("+key+" "+after+" "+keyParam+" OR ("+key+" = "+keyParam+" AND id "+after+" ?))")
+		args = append(args, f.After.Key, f.After.Key, f.After.ID)
+	}
+
+	query := "SELECT * FROM " + s.bookingsTable
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY " + key + " " + order + ", id " + order
+	if f.Limit > 0 {
+		query += " LIMIT " + strconv.Itoa(f.Limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var results []Booking
+	for rows.Next() {
+		var result Booking
+		if err := rows.Scan(
+			&result.ID,
+			&result.CreatedAt,
+			&result.CreatedBy,
+			&result.PropertyID,
+			&result.StartDate,
+			&result.EndDate,
+			&result.GuestName,
+			&result.Adults,
+			&result.Children,
+			&result.GuestID,
+			&result.Notes,
+			&result.Version); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// GetBookingsByStartDate returns the bookings of every property arriving on a date
+func (s *Service) GetBookingsByStartDate(date string) ([]Booking, error) {
+	defer s.lock("GetBookingsByStartDate")()
+
+	rows, err := s.db.Query("SELECT * FROM "+s.bookingsTable+" WHERE start_date = ?", date)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var results []Booking
+	for rows.Next() {
+		var result Booking
+		if err := rows.Scan(
+			&result.ID,
+			&result.CreatedAt,
+			&result.CreatedBy,
+			&result.PropertyID,
+			&result.StartDate,
+			&result.EndDate,
+			&result.GuestName,
+			&result.Adults,
+			&result.Children,
+			&result.GuestID,
+			&result.Notes,
+			&result.Version); err != nil {
 			return nil, err
 		}
 		results = append(results, result)
@@ -158,10 +422,9 @@ func (s *Service) GetBookingsByPropertyIds(propertyIDs []string) ([]Booking, err
 }
 
 func (s *Service) InsertBooking(result Booking) error {
-	s.m.Lock()
-	defer s.m.Unlock()
+	defer s.lock("InsertBooking")()
 	_, err := s.db.Exec("INSERT INTO "+s.bookingsTable+
-		" (id, created_at, created_by, property_id, start_date, end_date, guest_name, adults, children) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		" (id, created_at, created_by, property_id, start_date, end_date, guest_name, adults, children, guest_id, notes) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
 		result.ID,
 		result.CreatedAt,
 		result.CreatedBy,
@@ -170,7 +433,9 @@ func (s *Service) InsertBooking(result Booking) error {
 		result.EndDate,
 		result.GuestName,
 		result.Adults,
-		result.Children)
+		result.Children,
+		result.GuestID,
+		result.Notes)
 
 	if err != nil {
 		return err
@@ -179,31 +444,84 @@ func (s *Service) InsertBooking(result Booking) error {
 	return nil
 }
 
+// UpdateBooking stores the changes to a booking and increments its version. The
+// changes are based on result.Version: if the booking has changed since, it
+// returns ErrVersionConflict.
 func (s *Service) UpdateBooking(result Booking) error {
-	s.m.Lock()
-	defer s.m.Unlock()
-	_, err := s.db.Exec("UPDATE "+s.bookingsTable+
-		" SET start_date = ?, end_date = ?, guest_name = ?, adults = ?, children = ? WHERE id = ?",
+	defer s.lock("UpdateBooking")()
+	res, err := s.db.Exec("UPDATE "+s.bookingsTable+
+		" SET start_date = ?, end_date = ?, guest_name = ?, adults = ?, children = ?, guest_id = ?, notes = ?, version = version + 1 WHERE id = ? AND version = ?",
 		result.StartDate,
 		result.EndDate,
 		result.GuestName,
 		result.Adults,
 		result.Children,
-		result.ID)
+		result.GuestID,
+		result.Notes,
+		result.ID,
+		result.Version)
 
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return versionMatched(res)
 }
 
-func (s *Service) DeleteBooking(id string) error {
-	s.m.Lock()
-	defer s.m.Unlock()
-	_, err := s.db.Exec("DELETE FROM "+s.bookingsTable+" WHERE id = ?", id)
+// DeleteBooking removes a booking together with its guest registrations, comments and
+// attachment records, and returns the attachments so the caller can remove their blobs.
+// It returns ErrVersionConflict, and removes nothing, if the booking isn't at the given version.
+func (s *Service) DeleteBooking(id string, version int) ([]Attachment, error) {
+	defer s.lock("DeleteBooking")()
+
+	tx, err := s.db.Begin()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	defer tx.Rollback()
+
+	res, err := tx.Exec("DELETE FROM "+s.bookingsTable+" WHERE id = ? AND version = ?", id, version)
+	if err != nil {
+		return nil, err
+	}
+	if err := versionMatched(res); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM "+s.guestRegistrationsTable+" WHERE booking_id = ?", id); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM "+s.bookingCommentsTable+" WHERE booking_id = ?", id); err != nil {
+		return nil, err
+	}
+	attachments, err := s.deleteAttachments(tx, "parent_type = 'booking' AND parent_id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+
+	return attachments, tx.Commit()
+}
+
+func scanBookings(rows *sql.Rows) ([]Booking, error) {
+	defer rows.Close()
+	var results []Booking
+	for rows.Next() {
+		var result Booking
+		if err := rows.Scan(
+			&result.ID,
+			&result.CreatedAt,
+			&result.CreatedBy,
+			&result.PropertyID,
+			&result.StartDate,
+			&result.EndDate,
+			&result.GuestName,
+			&result.Adults,
+			&result.Children,
+			&result.GuestID,
+			&result.Notes,
+			&result.Version); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
 }
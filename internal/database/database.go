@@ -1,24 +1,43 @@
 package database
 
 import (
+	"booker-be/internal/metrics"
+	"context"
 	"database/sql"
 	"sync"
 	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
-	_ "github.com/joho/godotenv/autoload"
 	_ "github.com/mattn/go-sqlite3"
 )
 
 type Service struct {
 	db               *sql.DB
 	m                *sync.Mutex
+	ctx              context.Context // For log lines, see WithContext
 	bookingsTable    string
 	usersTable       string
 	propertyTable    string
 	groupsTable      string
 	groupsUsersTable string
 	groupCodesTable  string
+
+	touristTaxRulesTable string
+	guestsTable          string
+
+	guestRegistrationsTable string
+	bookingCommentsTable    string
+	activityTable           string
+	attachmentsTable        string
+
+	webhooksTable          string
+	webhookDeliveriesTable string
+
+	notificationPreferencesTable string
+	jobsTable                    string
+	idempotencyKeysTable         string
+
+	fullTextSearch bool // Whether the search_text table exists, see CreateSearchIndex
 }
 
 var (
@@ -29,12 +48,31 @@ var (
 	groupsUsersTable = "group_users"
 	groupCodesTable  = "group_codes"
 
+	touristTaxRulesTable = "tourist_tax_rules"
+	guestsTable          = "guests"
+
+	guestRegistrationsTable = "guest_registrations"
+	bookingCommentsTable    = "booking_comments"
+	activityTable           = "activity"
+	attachmentsTable        = "attachments"
+
+	webhooksTable          = "webhooks"
+	webhookDeliveriesTable = "webhook_deliveries"
+
+	notificationPreferencesTable = "notification_preferences"
+	jobsTable                    = "jobs"
+	idempotencyKeysTable         = "idempotency_keys"
+
 	dbInstance *Service
+
+	lockWait      = metrics.Default.NewHistogram("booker_db_lock_wait_seconds", "Time spent waiting for the database mutex, by Service method.", metrics.DefaultBuckets, "method")
+	queryDuration = metrics.Default.NewHistogram("booker_db_query_duration_seconds", "Time the database mutex was held, by Service method.", metrics.DefaultBuckets, "method")
 )
 
-func New() Service {
+// New opens the SQLite database at path and creates any missing tables
+func New(path string) Service {
 	var err error
-	db, err := sql.Open("sqlite3", "./bookings.db")
+	db, err := sql.Open(driverName, path)
 	if err != nil {
 		panic(err)
 	}
@@ -75,6 +113,78 @@ func New() Service {
 		panic(err)
 	}
 
+	// Create the tourist_tax_rules table if it doesn't exist
+	err = CreateTouristTaxRulesTable(db)
+	if err != nil {
+		panic(err)
+	}
+
+	// Create the guests table if it doesn't exist
+	err = CreateGuestsTable(db)
+	if err != nil {
+		panic(err)
+	}
+
+	// Create the guest_registrations table if it doesn't exist
+	err = CreateGuestRegistrationsTable(db)
+	if err != nil {
+		panic(err)
+	}
+
+	// Create the booking_comments table if it doesn't exist
+	err = CreateBookingCommentsTable(db)
+	if err != nil {
+		panic(err)
+	}
+
+	// Create the activity table if it doesn't exist
+	err = CreateActivityTable(db)
+	if err != nil {
+		panic(err)
+	}
+
+	// Create the attachments table if it doesn't exist
+	err = CreateAttachmentsTable(db)
+	if err != nil {
+		panic(err)
+	}
+
+	// Create the webhooks table if it doesn't exist
+	err = CreateWebhooksTable(db)
+	if err != nil {
+		panic(err)
+	}
+
+	// Create the webhook_deliveries table if it doesn't exist
+	err = CreateWebhookDeliveriesTable(db)
+	if err != nil {
+		panic(err)
+	}
+
+	// Create the notification_preferences table if it doesn't exist
+	err = CreateNotificationPreferencesTable(db)
+	if err != nil {
+		panic(err)
+	}
+
+	// Create the jobs table if it doesn't exist
+	err = CreateJobsTable(db)
+	if err != nil {
+		panic(err)
+	}
+
+	// Create the idempotency_keys table if it doesn't exist
+	err = CreateIdempotencyKeysTable(db)
+	if err != nil {
+		panic(err)
+	}
+
+	// Create the search_text table and its triggers if they don't exist and SQLite has FTS4
+	fullTextSearch, err := CreateSearchIndex(db)
+	if err != nil {
+		panic(err)
+	}
+
 	dbInstance = &Service{
 		db:               db,
 		bookingsTable:    bookingsTable,
@@ -84,20 +194,24 @@ func New() Service {
 		groupsUsersTable: groupsUsersTable,
 		groupCodesTable:  groupCodesTable,
 		m:                &sync.Mutex{},
-	}
 
-	go func() {
-		oneHour := 3600
-		for {
-			// Clean up expired group codes every hour
-			err := dbInstance.CleanUpExpiredGroupCodes()
-			if err != nil {
-				panic(err)
-			}
-			// Sleep for one hour
-			time.Sleep(time.Duration(oneHour) * time.Second)
-		}
-	}()
+		touristTaxRulesTable: touristTaxRulesTable,
+		guestsTable:          guestsTable,
+
+		guestRegistrationsTable: guestRegistrationsTable,
+		bookingCommentsTable:    bookingCommentsTable,
+		activityTable:           activityTable,
+		attachmentsTable:        attachmentsTable,
+
+		webhooksTable:          webhooksTable,
+		webhookDeliveriesTable: webhookDeliveriesTable,
+
+		notificationPreferencesTable: notificationPreferencesTable,
+		jobsTable:                    jobsTable,
+		idempotencyKeysTable:         idempotencyKeysTable,
+
+		fullTextSearch: fullTextSearch,
+	}
 
 	return *dbInstance
 }
@@ -105,3 +219,30 @@ func New() Service {
 func (s *Service) Close() error {
 	return s.db.Close()
 }
+
+// WithContext returns a copy of the service sharing its connection, whose log lines
+// carry the attributes of ctx, such as the request ID
+func (s Service) WithContext(ctx context.Context) Service {
+	s.ctx = ctx
+	return s
+}
+
+// lock takes the database mutex for a method and returns the function releasing it.
+// The time spent waiting for the mutex and holding it are recorded per method.
+func (s *Service) lock(method string) (unlock func()) {
+	start := time.Now()
+	s.m.Lock()
+	acquired := time.Now()
+	lockWait.Observe(acquired.Sub(start).Seconds(), method)
+	return func() {
+		s.m.Unlock()
+		queryDuration.Observe(time.Since(acquired).Seconds(), method)
+	}
+}
+
+func (s *Service) context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
@@ -11,7 +11,8 @@ func CreateGroupsTable(db *sql.DB) error {
 		id string not null primary key,
 		created_at string,
 		name string not null,
-		owner_id string not null
+		owner_id string not null,
+		version integer not null default 1
 	);
 	`
 
@@ -20,6 +21,12 @@ func CreateGroupsTable(db *sql.DB) error {
 		return err
 	}
 
+	// Migration: Add the version for optimistic concurrency control
+	_, err = db.Exec(`ALTER TABLE groups ADD COLUMN version integer NOT NULL DEFAULT 1;`)
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
 	return nil
 }
 
@@ -28,8 +35,7 @@ func (s *Service) GetGroupsTableName() string {
 }
 
 func (s *Service) GetAllGroups() ([]Group, error) {
-	s.m.Lock()
-	defer s.m.Unlock()
+	defer s.lock("GetAllGroups")()
 
 	rows, err := s.db.Query("SELECT * FROM " + s.groupsTable)
 	if err != nil {
@@ -44,7 +50,8 @@ func (s *Service) GetAllGroups() ([]Group, error) {
 			&result.ID,
 			&result.CreatedAt,
 			&result.Name,
-			&result.OwnerID); err != nil {
+			&result.OwnerID,
+			&result.Version); err != nil {
 			return nil, err
 		}
 		results = append(results, result)
@@ -54,14 +61,14 @@ func (s *Service) GetAllGroups() ([]Group, error) {
 }
 
 func (s *Service) GetGroupByID(id string) (Group, error) {
-	s.m.Lock()
-	defer s.m.Unlock()
+	defer s.lock("GetGroupByID")()
 	var result Group
 	err := s.db.QueryRow("SELECT * FROM "+s.groupsTable+" WHERE id = ?", id).Scan(
 		&result.ID,
 		&result.CreatedAt,
 		&result.Name,
-		&result.OwnerID)
+		&result.OwnerID,
+		&result.Version)
 	if err != nil {
 		return Group{}, err
 	}
@@ -69,8 +76,7 @@ func (s *Service) GetGroupByID(id string) (Group, error) {
 }
 
 func (s *Service) GetGroupsByID(ids []string) ([]Group, error) {
-	s.m.Lock()
-	defer s.m.Unlock()
+	defer s.lock("GetGroupsByID")()
 	if len(ids) == 0 {
 		return nil, nil // Return empty slice if no IDs are provided
 	}
@@ -93,7 +99,8 @@ func (s *Service) GetGroupsByID(ids []string) ([]Group, error) {
 			&result.ID,
 			&result.CreatedAt,
 			&result.Name,
-			&result.OwnerID); err != nil {
+			&result.OwnerID,
+			&result.Version); err != nil {
 			return nil, err
 		}
 		results = append(results, result)
@@ -102,8 +109,7 @@ func (s *Service) GetGroupsByID(ids []string) ([]Group, error) {
 }
 
 func (s *Service) GetGroupByOwnerID(ownerID string) ([]Group, error) {
-	s.m.Lock()
-	defer s.m.Unlock()
+	defer s.lock("GetGroupByOwnerID")()
 
 	rows, err := s.db.Query("SELECT * FROM "+s.groupsTable+" WHERE owner_id = ?", ownerID)
 	if err != nil {
@@ -118,7 +124,8 @@ func (s *Service) GetGroupByOwnerID(ownerID string) ([]Group, error) {
 			&result.ID,
 			&result.CreatedAt,
 			&result.Name,
-			&result.OwnerID); err != nil {
+			&result.OwnerID,
+			&result.Version); err != nil {
 			return nil, err
 		}
 		results = append(results, result)
@@ -128,8 +135,7 @@ func (s *Service) GetGroupByOwnerID(ownerID string) ([]Group, error) {
 }
 
 func (s *Service) InsertGroup(result Group) error {
-	s.m.Lock()
-	defer s.m.Unlock()
+	defer s.lock("InsertGroup")()
 	_, err := s.db.Exec("INSERT INTO "+s.groupsTable+
 		" (id, created_at, name, owner_id) VALUES (?, ?, ?, ?)",
 		result.ID,
@@ -145,8 +151,7 @@ func (s *Service) InsertGroup(result Group) error {
 }
 
 func (s *Service) DeleteGroupByID(id string) error {
-	s.m.Lock()
-	defer s.m.Unlock()
+	defer s.lock("DeleteGroupByID")()
 	_, err := s.db.Exec("DELETE FROM "+s.groupsTable+" WHERE id = ?", id)
 	if err != nil {
 		return err
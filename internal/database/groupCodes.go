@@ -2,7 +2,7 @@ package database
 
 import (
 	"database/sql"
-	"fmt"
+	"log/slog"
 	"time"
 )
 
@@ -29,8 +29,7 @@ func (s *Service) GetGroupCodesTableName() string {
 }
 
 func (s *Service) GetAllGroupCodes() ([]GroupCode, error) {
-	s.m.Lock()
-	defer s.m.Unlock()
+	defer s.lock("GetAllGroupCodes")()
 
 	rows, err := s.db.Query("SELECT * FROM " + s.groupCodesTable)
 	if err != nil {
@@ -55,8 +54,7 @@ func (s *Service) GetAllGroupCodes() ([]GroupCode, error) {
 }
 
 func (s *Service) GetGroupCodeByID(id string) (GroupCode, error) {
-	s.m.Lock()
-	defer s.m.Unlock()
+	defer s.lock("GetGroupCodeByID")()
 	var result GroupCode
 	err := s.db.QueryRow("SELECT * FROM "+s.groupCodesTable+" WHERE id = ?", id).Scan(
 		&result.ID,
@@ -70,8 +68,7 @@ func (s *Service) GetGroupCodeByID(id string) (GroupCode, error) {
 }
 
 func (s *Service) GetGroupCodeByCode(code string) (GroupCode, error) {
-	s.m.Lock()
-	defer s.m.Unlock()
+	defer s.lock("GetGroupCodeByCode")()
 
 	var result GroupCode
 	err := s.db.QueryRow("SELECT * FROM "+s.groupCodesTable+" WHERE code = ?", code).Scan(
@@ -86,8 +83,7 @@ func (s *Service) GetGroupCodeByCode(code string) (GroupCode, error) {
 }
 
 func (s *Service) InsertGroupCode(result GroupCode) error {
-	s.m.Lock()
-	defer s.m.Unlock()
+	defer s.lock("InsertGroupCode")()
 	_, err := s.db.Exec("INSERT INTO "+s.groupCodesTable+
 		" (id, group_id, code, active_to) VALUES (?, ?, ?, ?)",
 		result.ID,
@@ -102,24 +98,24 @@ func (s *Service) InsertGroupCode(result GroupCode) error {
 }
 
 func (s *Service) CleanUpExpiredGroupCodes() error {
-	fmt.Println("Cleaning up expired group codes, current time:", time.Now().Format(time.RFC3339))
 	codes, err := s.GetAllGroupCodes()
-	fmt.Println("Retrieved group codes for cleanup:", len(codes))
 	if err != nil {
 		return err
 	}
+	deleted := 0
 	for _, code := range codes {
 		if code.ActiveTo < time.Now().Format(time.RFC3339) {
-			s.m.Lock()
+			unlock := s.lock("CleanUpExpiredGroupCodes")
 			_, err := s.db.Exec("DELETE FROM "+s.groupCodesTable+" WHERE id = ?", code.ID)
-			s.m.Unlock()
+			unlock()
 			if err != nil {
 				return err
 			}
+			deleted++
 		}
 	}
 
-	fmt.Println("Expired group codes cleanup completed.")
+	slog.InfoContext(s.context(), "Expired group codes cleaned up", "checked", len(codes), "deleted", deleted)
 
 	return nil
 }
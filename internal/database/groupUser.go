@@ -30,8 +30,7 @@ func (s *Service) GetGroupUsersTableName() string {
 }
 
 func (s *Service) GetAllGroupUsersByGroupID(groupID string) ([]GroupUser, error) {
-	s.m.Lock()
-	defer s.m.Unlock()
+	defer s.lock("GetAllGroupUsersByGroupID")()
 
 	rows, err := s.db.Query("SELECT * FROM "+s.groupsUsersTable+" WHERE group_id = ?", groupID)
 	if err != nil {
@@ -52,8 +51,7 @@ func (s *Service) GetAllGroupUsersByGroupID(groupID string) ([]GroupUser, error)
 }
 
 func (s *Service) GetAllGroupUsersByUserID(userID string) ([]GroupUser, error) {
-	s.m.Lock()
-	defer s.m.Unlock()
+	defer s.lock("GetAllGroupUsersByUserID")()
 
 	rows, err := s.db.Query("SELECT * FROM "+s.groupsUsersTable+" WHERE user_id = ?", userID)
 	if err != nil {
@@ -74,8 +72,7 @@ func (s *Service) GetAllGroupUsersByUserID(userID string) ([]GroupUser, error) {
 }
 
 func (s *Service) GetGroupUserByUserIDAndGroupID(userID, groupID string) (GroupUser, error) {
-	s.m.Lock()
-	defer s.m.Unlock()
+	defer s.lock("GetGroupUserByUserIDAndGroupID")()
 
 	var result GroupUser
 	err := s.db.QueryRow("SELECT * FROM "+s.groupsUsersTable+" WHERE user_id = ? AND group_id = ?", userID, groupID).Scan(
@@ -89,8 +86,7 @@ func (s *Service) GetGroupUserByUserIDAndGroupID(userID, groupID string) (GroupU
 }
 
 func (s *Service) InsertGroupUser(result GroupUser) error {
-	s.m.Lock()
-	defer s.m.Unlock()
+	defer s.lock("InsertGroupUser")()
 
 	_, err := s.db.Exec("INSERT INTO "+s.groupsUsersTable+" (id, group_id, user_id) VALUES (?, ?, ?)",
 		result.ID, result.GroupID, result.UserID)
@@ -125,3 +121,38 @@ func (s *Service) UserCanAccessBooking(userID, bookingID string) bool {
 	}
 	return s.UserBelongsToPropertyGroup(userID, booking.PropertyID)
 }
+
+// UserCanAccessGuest checks if a user belongs to the group whose directory holds a guest
+func (s *Service) UserCanAccessGuest(userID, guestID string) bool {
+	guest, err := s.GetGuestByID(guestID)
+	if err != nil {
+		return false
+	}
+	return s.UserBelongsToGroup(userID, guest.GroupID)
+}
+
+// UserCanAccessGuestRegistration checks if a user can access the booking a guest registration belongs to
+func (s *Service) UserCanAccessGuestRegistration(userID, registrationID string) bool {
+	registration, err := s.GetGuestRegistrationByID(registrationID)
+	if err != nil {
+		return false
+	}
+	return s.UserCanAccessBooking(userID, registration.BookingID)
+}
+
+// GuestRegistrationInGroup checks if a guest registration is for a booking of one of a group's properties
+func (s *Service) GuestRegistrationInGroup(registrationID, groupID string) bool {
+	registration, err := s.GetGuestRegistrationByID(registrationID)
+	if err != nil {
+		return false
+	}
+	booking, err := s.GetBookingByID(registration.BookingID)
+	if err != nil {
+		return false
+	}
+	property, err := s.GetPropertyByID(booking.PropertyID)
+	if err != nil {
+		return false
+	}
+	return property.GroupID == groupID
+}
@@ -0,0 +1,236 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+)
+
+func CreateGuestRegistrationsTable(db *sql.DB) error {
+	sqlStmt := `
+	create table if not exists guest_registrations (
+		id string not null primary key,
+		created_at string,
+		booking_id string not null,
+		first_name string not null,
+		last_name string not null,
+		birth_date string not null,
+		nationality string not null,
+		document_type string not null,
+		document_number string not null,
+		reported integer default 0,
+		reported_at string default ''
+	);
+	`
+
+	_, err := db.Exec(sqlStmt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Service) GetGuestRegistrationsTableName() string {
+	return s.guestRegistrationsTable
+}
+
+func (s *Service) GetGuestRegistrationByID(id string) (GuestRegistration, error) {
+	defer s.lock("GetGuestRegistrationByID")()
+	var result GuestRegistration
+	err := s.db.QueryRow("SELECT * FROM "+s.guestRegistrationsTable+" WHERE id = ?", id).Scan(
+		&result.ID,
+		&result.CreatedAt,
+		&result.BookingID,
+		&result.FirstName,
+		&result.LastName,
+		&result.BirthDate,
+		&result.Nationality,
+		&result.DocumentType,
+		&result.DocumentNumber,
+		&result.Reported,
+		&result.ReportedAt)
+	if err != nil {
+		return GuestRegistration{}, err
+	}
+	return result, nil
+}
+
+func (s *Service) GetGuestRegistrationsByBookingID(bookingID string) ([]GuestRegistration, error) {
+	return s.GetGuestRegistrationsByBookingIDs([]string{bookingID})
+}
+
+func (s *Service) GetGuestRegistrationsByBookingIDs(bookingIDs []string) ([]GuestRegistration, error) {
+	defer s.lock("GetGuestRegistrationsByBookingIDs")()
+	if len(bookingIDs) == 0 {
+		return nil, nil
+	}
+
+	query := "SELECT * FROM " + s.guestRegistrationsTable + " WHERE booking_id IN (?" + strings.Repeat(",?", len(bookingIDs)-1) + ") ORDER BY created_at"
+	args := make([]any, len(bookingIDs))
+	for i, id := range bookingIDs {
+		args[i] = id
+	}
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []GuestRegistration
+	for rows.Next() {
+		var result GuestRegistration
+		if err := rows.Scan(
+			&result.ID,
+			&result.CreatedAt,
+			&result.BookingID,
+			&result.FirstName,
+			&result.LastName,
+			&result.BirthDate,
+			&result.Nationality,
+			&result.DocumentType,
+			&result.DocumentNumber,
+			&result.Reported,
+			&result.ReportedAt); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func (s *Service) InsertGuestRegistration(result GuestRegistration) error {
+	defer s.lock("InsertGuestRegistration")()
+	_, err := s.db.Exec("INSERT INTO "+s.guestRegistrationsTable+
+		" (id, created_at, booking_id, first_name, last_name, birth_date, nationality, document_type, document_number, reported, reported_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		result.ID,
+		result.CreatedAt,
+		result.BookingID,
+		result.FirstName,
+		result.LastName,
+		result.BirthDate,
+		result.Nationality,
+		result.DocumentType,
+		result.DocumentNumber,
+		result.Reported,
+		result.ReportedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateGuestRegistration changes a registration's details. A changed
+// registration has to be reported again, so its reported flag is cleared.
+func (s *Service) UpdateGuestRegistration(result GuestRegistration) error {
+	defer s.lock("UpdateGuestRegistration")()
+	_, err := s.db.Exec("UPDATE "+s.guestRegistrationsTable+
+		" SET first_name = ?, last_name = ?, birth_date = ?, nationality = ?, document_type = ?, document_number = ?, reported = 0, reported_at = '' WHERE id = ?",
+		result.FirstName,
+		result.LastName,
+		result.BirthDate,
+		result.Nationality,
+		result.DocumentType,
+		result.DocumentNumber,
+		result.ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SetGuestRegistrationsReported sets the reported flag of the given registrations.
+// reportedAt is cleared when the flag is removed.
+func (s *Service) SetGuestRegistrationsReported(ids []string, reported bool, reportedAt string) error {
+	defer s.lock("SetGuestRegistrationsReported")()
+	if len(ids) == 0 {
+		return nil
+	}
+	if !reported {
+		reportedAt = ""
+	}
+
+	query := "UPDATE " + s.guestRegistrationsTable + " SET reported = ?, reported_at = ? WHERE id IN (?" + strings.Repeat(",?", len(ids)-1) + ")"
+	args := make([]any, 0, len(ids)+2)
+	args = append(args, reported, reportedAt)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	_, err := s.db.Exec(query, args...)
+	return err
+}
+
+// GuestRegistrationFilter selects the registrations of a group's stays to export
+type GuestRegistrationFilter struct {
+	GroupID  string
+	From     string // First arrival date, YYYY-MM-DD
+	To       string // Arrival date to stop before
+	Reported *bool  // Only registrations with this flag, nil for all
+}
+
+// ExportGuestRegistrations returns the registrations matching f with their stays,
+// ordered by arrival
+func (s *Service) ExportGuestRegistrations(f GuestRegistrationFilter) ([]ExportedGuestRegistration, error) {
+	defer s.lock("ExportGuestRegistrations")()
+
+	query := "SELECT r.*, p.name, b.start_date, b.end_date FROM " + s.guestRegistrationsTable + " r" +
+		" JOIN " + s.bookingsTable + " b ON b.id = r.booking_id" +
+		" JOIN " + s.propertyTable + " p ON p.id = b.property_id" +
+		" WHERE p.group_id = ?"
+	args := []any{f.GroupID}
+	if f.From != "" {
+		query += " AND b.start_date >= ?"
+		args = append(args, f.From)
+	}
+	if f.To != "" {
+		query += " AND b.start_date < ?"
+		args = append(args, f.To)
+	}
+	if f.Reported != nil {
+		query += " AND r.reported = ?"
+		args = append(args, *f.Reported)
+	}
+	query += " ORDER BY b.start_date, r.created_at"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []ExportedGuestRegistration
+	for rows.Next() {
+		var result ExportedGuestRegistration
+		if err := rows.Scan(
+			&result.ID,
+			&result.CreatedAt,
+			&result.BookingID,
+			&result.FirstName,
+			&result.LastName,
+			&result.BirthDate,
+			&result.Nationality,
+			&result.DocumentType,
+			&result.DocumentNumber,
+			&result.Reported,
+			&result.ReportedAt,
+			&result.PropertyName,
+			&result.ArrivalDate,
+			&result.DepartureDate); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func (s *Service) DeleteGuestRegistration(id string) error {
+	defer s.lock("DeleteGuestRegistration")()
+	_, err := s.db.Exec("DELETE FROM "+s.guestRegistrationsTable+" WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+)
+
+func CreateGuestsTable(db *sql.DB) error {
+	sqlStmt := `
+	create table if not exists guests (
+		id string not null primary key,
+		created_at string,
+		group_id string not null,
+		name string not null,
+		email string default '',
+		phone string default '',
+		country string default '',
+		document_number string default '',
+		notes string default ''
+	);
+	`
+
+	_, err := db.Exec(sqlStmt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Service) GetGuestsTableName() string {
+	return s.guestsTable
+}
+
+func (s *Service) GetGuestByID(id string) (Guest, error) {
+	defer s.lock("GetGuestByID")()
+	var result Guest
+	err := s.db.QueryRow("SELECT * FROM "+s.guestsTable+" WHERE id = ?", id).Scan(
+		&result.ID,
+		&result.CreatedAt,
+		&result.GroupID,
+		&result.Name,
+		&result.Email,
+		&result.Phone,
+		&result.Country,
+		&result.DocumentNumber,
+		&result.Notes)
+	if err != nil {
+		return Guest{}, err
+	}
+	return result, nil
+}
+
+func (s *Service) GetGuestsByGroupID(groupID string) ([]Guest, error) {
+	defer s.lock("GetGuestsByGroupID")()
+
+	rows, err := s.db.Query("SELECT * FROM "+s.guestsTable+" WHERE group_id = ? ORDER BY name", groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []Guest
+	for rows.Next() {
+		var result Guest
+		if err := rows.Scan(
+			&result.ID,
+			&result.CreatedAt,
+			&result.GroupID,
+			&result.Name,
+			&result.Email,
+			&result.Phone,
+			&result.Country,
+			&result.DocumentNumber,
+			&result.Notes); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// containsPattern makes a LIKE pattern matching text that contains s, for use with
+// ESCAPE '\'. The wildcards % and _ in s match only themselves.
+func containsPattern(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+	return "%" + s + "%"
+}
+
+// SearchGuests finds the guests of a group whose name, email, phone or document
+// number contains the query, ignoring case
+func (s *Service) SearchGuests(groupID, query string) ([]Guest, error) {
+	defer s.lock("SearchGuests")()
+
+	pattern := containsPattern(strings.ToLower(query))
+	rows, err := s.db.Query("SELECT * FROM "+s.guestsTable+
+		" WHERE group_id = ? AND (lower(name) LIKE ? ESCAPE '\\' OR lower(email) LIKE ? ESCAPE '\\'"+
+		" OR phone LIKE ? ESCAPE '\\' OR lower(document_number) LIKE ? ESCAPE '\\') ORDER BY name",
+		groupID, pattern, pattern, pattern, pattern)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []Guest
+	for rows.Next() {
+		var result Guest
+		if err := rows.Scan(
+			&result.ID,
+			&result.CreatedAt,
+			&result.GroupID,
+			&result.Name,
+			&result.Email,
+			&result.Phone,
+			&result.Country,
+			&result.DocumentNumber,
+			&result.Notes); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func (s *Service) InsertGuest(result Guest) error {
+	defer s.lock("InsertGuest")()
+	_, err := s.db.Exec("INSERT INTO "+s.guestsTable+
+		" (id, created_at, group_id, name, email, phone, country, document_number, notes) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		result.ID,
+		result.CreatedAt,
+		result.GroupID,
+		result.Name,
+		result.Email,
+		result.Phone,
+		result.Country,
+		result.DocumentNumber,
+		result.Notes)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Service) UpdateGuest(result Guest) error {
+	defer s.lock("UpdateGuest")()
+	_, err := s.db.Exec("UPDATE "+s.guestsTable+
+		" SET name = ?, email = ?, phone = ?, country = ?, document_number = ?, notes = ? WHERE id = ?",
+		result.Name,
+		result.Email,
+		result.Phone,
+		result.Country,
+		result.DocumentNumber,
+		result.Notes,
+		result.ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteGuest removes a guest and unlinks their bookings.
+// The bookings themselves keep their guest_name.
+func (s *Service) DeleteGuest(id string) error {
+	defer s.lock("DeleteGuest")()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE "+s.bookingsTable+" SET guest_id = '', version = version + 1 WHERE guest_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM "+s.guestsTable+" WHERE id = ?", id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Columns added to existing tables by migrations in the Create...Table functions
+var migratedColumns = map[string][]string{
+	bookingsTable: {"adults", "children", "guest_id", "notes", "version"},
+	propertyTable: {"color", "version"},
+	groupsTable:   {"version"},
+	usersTable:    {"email"},
+
+	webhookDeliveriesTable: {"claimed_until"},
+}
+
+// Ping checks that the database can be reached. It doesn't take the mutex, so a
+// slow query elsewhere doesn't make the database look unavailable.
+func (s *Service) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// CheckSchema reports tables and migrated columns that are missing
+func (s *Service) CheckSchema(ctx context.Context) error {
+	tables := []string{
+		s.bookingsTable, s.usersTable, s.propertyTable, s.groupsTable, s.groupsUsersTable, s.groupCodesTable,
+		s.touristTaxRulesTable, s.guestsTable,
+		s.guestRegistrationsTable, s.bookingCommentsTable, s.activityTable, s.attachmentsTable,
+		s.webhooksTable, s.webhookDeliveriesTable,
+		s.notificationPreferencesTable, s.jobsTable, s.idempotencyKeysTable,
+	}
+
+	var missing []string
+	for _, table := range tables {
+		rows, err := s.db.QueryContext(ctx, "SELECT name FROM pragma_table_info(?)", table)
+		if err != nil {
+			return err
+		}
+		var columns []string
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				rows.Close()
+				return err
+			}
+			columns = append(columns, name)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(columns) == 0 {
+			missing = append(missing, table)
+			continue
+		}
+		for _, column := range migratedColumns[table] {
+			if !slices.Contains(columns, column) {
+				missing = append(missing, table+"."+column)
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("missing %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
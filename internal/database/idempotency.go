@@ -0,0 +1,118 @@
+package database
+
+import (
+	"database/sql"
+	"log/slog"
+)
+
+// The idempotency_keys table remembers the responses to POST requests sent with an
+// Idempotency-Key header, so that retries get the same response instead of
+// creating the resource again. Keys are scoped to the user who sent them.
+func CreateIdempotencyKeysTable(db *sql.DB) error {
+	sqlStmt := `
+	create table if not exists idempotency_keys (
+		user_id string not null,
+		key string not null,
+		fingerprint string not null,
+		created_at integer not null,
+		status integer default 0,
+		headers string default '',
+		body blob,
+		primary key (user_id, key)
+	);
+	`
+
+	_, err := db.Exec(sqlStmt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ReserveIdempotencyKey records that the request of k is being handled. It fails when
+// the key is already recorded, unless it was created before expiredBefore (unix
+// seconds), in which case k takes its place.
+func (s *Service) ReserveIdempotencyKey(k IdempotencyKey, expiredBefore int64) (bool, error) {
+	defer s.lock("ReserveIdempotencyKey")()
+	res, err := s.db.Exec("INSERT INTO "+s.idempotencyKeysTable+" (user_id, key, fingerprint, created_at) VALUES (?, ?, ?, ?)"+
+		" ON CONFLICT (user_id, key) DO UPDATE SET fingerprint = excluded.fingerprint, created_at = excluded.created_at,"+
+		" status = 0, headers = '', body = NULL WHERE created_at < ?",
+		k.UserID,
+		k.Key,
+		k.Fingerprint,
+		k.CreatedAt,
+		expiredBefore)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (s *Service) GetIdempotencyKey(userID, key string) (IdempotencyKey, error) {
+	defer s.lock("GetIdempotencyKey")()
+	var result IdempotencyKey
+	err := s.db.QueryRow("SELECT * FROM "+s.idempotencyKeysTable+" WHERE user_id = ? AND key = ?", userID, key).Scan(
+		&result.UserID,
+		&result.Key,
+		&result.Fingerprint,
+		&result.CreatedAt,
+		&result.Status,
+		&result.Headers,
+		&result.Body)
+	if err != nil {
+		return IdempotencyKey{}, err
+	}
+
+	return result, nil
+}
+
+// CompleteIdempotencyKey stores the response to the request of k
+func (s *Service) CompleteIdempotencyKey(k IdempotencyKey) error {
+	defer s.lock("CompleteIdempotencyKey")()
+	_, err := s.db.Exec("UPDATE "+s.idempotencyKeysTable+" SET status = ?, headers = ?, body = ? WHERE user_id = ? AND key = ?",
+		k.Status,
+		k.Headers,
+		k.Body,
+		k.UserID,
+		k.Key)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteIdempotencyKey forgets a key, so that a retry handles the request again
+func (s *Service) DeleteIdempotencyKey(userID, key string) error {
+	defer s.lock("DeleteIdempotencyKey")()
+	_, err := s.db.Exec("DELETE FROM "+s.idempotencyKeysTable+" WHERE user_id = ? AND key = ?", userID, key)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CleanUpIdempotencyKeys deletes the keys created before the given time (unix seconds)
+func (s *Service) CleanUpIdempotencyKeys(before int64) error {
+	unlock := s.lock("CleanUpIdempotencyKeys")
+	res, err := s.db.Exec("DELETE FROM "+s.idempotencyKeysTable+" WHERE created_at < ?", before)
+	unlock()
+	if err != nil {
+		return err
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(s.context(), "Expired idempotency keys cleaned up", "deleted", deleted)
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package database
+
+import (
+	"database/sql"
+)
+
+// The jobs table holds one row per scheduled job. It records the outcome of the
+// last run and doubles as a lease, so that when several instances share the
+// database each scheduled run happens on only one of them.
+func CreateJobsTable(db *sql.DB) error {
+	sqlStmt := `
+	create table if not exists jobs (
+		name string not null primary key,
+		schedule string not null,
+		locked_by string default '',
+		locked_until integer default 0,
+		last_scheduled_at integer default 0,
+		last_started_at string default '',
+		last_finished_at string default '',
+		last_status string default '',
+		last_error string default '',
+		last_duration_ms integer default 0,
+		run_count integer default 0
+	);
+	`
+
+	_, err := db.Exec(sqlStmt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Service) GetJobsTableName() string {
+	return s.jobsTable
+}
+
+func (s *Service) GetAllJobs() ([]JobStatus, error) {
+	defer s.lock("GetAllJobs")()
+
+	rows, err := s.db.Query("SELECT * FROM " + s.jobsTable + " ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []JobStatus
+	for rows.Next() {
+		var result JobStatus
+		if err := rows.Scan(
+			&result.Name,
+			&result.Schedule,
+			&result.LockedBy,
+			&result.LockedUntil,
+			&result.LastScheduledAt,
+			&result.LastStartedAt,
+			&result.LastFinishedAt,
+			&result.LastStatus,
+			&result.LastError,
+			&result.LastDurationMs,
+			&result.RunCount); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// EnsureJob registers a job, keeping the history of a job registered before
+func (s *Service) EnsureJob(name, schedule string) error {
+	defer s.lock("EnsureJob")()
+	_, err := s.db.Exec("INSERT INTO "+s.jobsTable+" (name, schedule) VALUES (?, ?)"+
+		" ON CONFLICT (name) DO UPDATE SET schedule = excluded.schedule",
+		name,
+		schedule)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AcquireJobLease claims the run of a job scheduled at slot (unix seconds) for holder
+// until the lease expires. It fails when the job is still leased by anyone or the
+// slot, or a later one, was already claimed.
+func (s *Service) AcquireJobLease(name, holder string, slot, now, until int64, startedAt string) (bool, error) {
+	defer s.lock("AcquireJobLease")()
+	res, err := s.db.Exec("UPDATE "+s.jobsTable+
+		" SET locked_by = ?, locked_until = ?, last_scheduled_at = ?, last_started_at = ?, last_status = ?"+
+		" WHERE name = ? AND locked_until < ? AND last_scheduled_at < ?",
+		holder,
+		until,
+		slot,
+		startedAt,
+		JobStatusRunning,
+		name,
+		now,
+		slot)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// AcquireTriggeredJobLease leases a job for holder until the lease expires, for a run
+// outside its schedule. It fails when the job is still leased by anyone, and leaves
+// the scheduled slots claimed by AcquireJobLease as they are.
+func (s *Service) AcquireTriggeredJobLease(name, holder string, now, until int64, startedAt string) (bool, error) {
+	defer s.lock("AcquireTriggeredJobLease")()
+	res, err := s.db.Exec("UPDATE "+s.jobsTable+
+		" SET locked_by = ?, locked_until = ?, last_started_at = ?, last_status = ?"+
+		" WHERE name = ? AND locked_until < ?",
+		holder,
+		until,
+		startedAt,
+		JobStatusRunning,
+		name,
+		now)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// FinishJobRun records the outcome of a run and releases the lease
+func (s *Service) FinishJobRun(result JobStatus) error {
+	defer s.lock("FinishJobRun")()
+	_, err := s.db.Exec("UPDATE "+s.jobsTable+
+		" SET locked_by = '', locked_until = 0, last_started_at = ?, last_finished_at = ?, last_status = ?, last_error = ?,"+
+		" last_duration_ms = ?, run_count = run_count + 1 WHERE name = ?",
+		result.LastStartedAt,
+		result.LastFinishedAt,
+		result.LastStatus,
+		result.LastError,
+		result.LastDurationMs,
+		result.Name)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
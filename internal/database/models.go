@@ -4,6 +4,7 @@ type User struct {
 	ID             string `json:"id"`
 	Username       string `json:"username"`
 	HashedPassword string `json:"hashed_password"`
+	Email          string `json:"email"`
 }
 
 type Group struct {
@@ -11,6 +12,7 @@ type Group struct {
 	CreatedAt string `json:"created_at"`
 	Name      string `json:"name"`
 	OwnerID   string `json:"owner_id"`
+	Version   int    `json:"version"` // Incremented on every change, see ErrVersionConflict
 }
 
 type Property struct {
@@ -19,6 +21,7 @@ type Property struct {
 	GroupID   string `json:"group_id"`
 	Name      string `json:"name"`
 	Color     string `json:"color"`
+	Version   int    `json:"version"`
 }
 
 type Booking struct {
@@ -31,6 +34,56 @@ type Booking struct {
 	GuestName  string `json:"guest_name"`
 	Adults     int    `json:"adults"`
 	Children   int    `json:"children"`
+	GuestID    string `json:"guest_id"`
+	Notes      string `json:"notes"`
+	Version    int    `json:"version"`
+}
+
+// Booking statuses, relative to a day
+const (
+	BookingStatusUpcoming = "upcoming" // Arrives after the day
+	BookingStatusCurrent  = "current"  // Stays the night of the day
+	BookingStatusPast     = "past"     // Left on or before the day
+)
+
+// Orders of booking listings
+const (
+	BookingSortStartDate = "start_date"
+	BookingSortCreatedAt = "created_at"
+)
+
+type Guest struct {
+	ID             string `json:"id"`
+	CreatedAt      string `json:"created_at"`
+	GroupID        string `json:"group_id"`
+	Name           string `json:"name"`
+	Email          string `json:"email"`
+	Phone          string `json:"phone"`
+	Country        string `json:"country"`
+	DocumentNumber string `json:"document_number"`
+	Notes          string `json:"notes"`
+}
+
+type GuestRegistration struct {
+	ID             string `json:"id"`
+	CreatedAt      string `json:"created_at"`
+	BookingID      string `json:"booking_id"`
+	FirstName      string `json:"first_name"`
+	LastName       string `json:"last_name"`
+	BirthDate      string `json:"birth_date"`
+	Nationality    string `json:"nationality"`
+	DocumentType   string `json:"document_type"`
+	DocumentNumber string `json:"document_number"`
+	Reported       bool   `json:"reported"`
+	ReportedAt     string `json:"reported_at"`
+}
+
+// ExportedGuestRegistration is a guest registration with the stay it is for
+type ExportedGuestRegistration struct {
+	GuestRegistration
+	PropertyName  string
+	ArrivalDate   string
+	DepartureDate string
 }
 
 type GroupUser struct {
@@ -45,3 +98,143 @@ type GroupCode struct {
 	Code     string `json:"code"`
 	ActiveTo string `json:"active_to"`
 }
+
+type TouristTaxRule struct {
+	ID             string  `json:"id"`
+	CreatedAt      string  `json:"created_at"`
+	GroupID        string  `json:"group_id"`
+	PropertyID     string  `json:"property_id"`
+	AdultRate      float64 `json:"adult_rate"`
+	ChildRate      float64 `json:"child_rate"`
+	ChildUnderAge  int     `json:"child_under_age"`
+	ExemptUnderAge int     `json:"exempt_under_age"`
+	Currency       string  `json:"currency"`
+	ValidFrom      string  `json:"valid_from"`
+	ValidTo        string  `json:"valid_to"`
+}
+
+type BookingComment struct {
+	ID         string `json:"id"`
+	CreatedAt  string `json:"created_at"`
+	UpdatedAt  string `json:"updated_at"`
+	BookingID  string `json:"booking_id"`
+	ParentID   string `json:"parent_id"`
+	AuthorID   string `json:"author_id"`
+	AuthorName string `json:"author_name"`
+	Body       string `json:"body"`
+	Deleted    bool   `json:"deleted"`
+}
+
+type Activity struct {
+	ID         string `json:"id"`
+	CreatedAt  string `json:"created_at"`
+	GroupID    string `json:"group_id"`
+	UserID     string `json:"user_id"`
+	Action     string `json:"action"`
+	EntityType string `json:"entity_type"`
+	EntityID   string `json:"entity_id"`
+	Summary    string `json:"summary"`
+}
+
+type Attachment struct {
+	ID          string `json:"id"`
+	CreatedAt   string `json:"created_at"`
+	CreatedBy   string `json:"created_by"`
+	GroupID     string `json:"group_id"`
+	ParentType  string `json:"parent_type"`
+	ParentID    string `json:"parent_id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	StorageKey  string `json:"-"`
+}
+
+type Webhook struct {
+	ID         string   `json:"id"`
+	CreatedAt  string   `json:"created_at"`
+	CreatedBy  string   `json:"created_by"`
+	GroupID    string   `json:"group_id"`
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Secret     string   `json:"-"`
+	Active     bool     `json:"active"`
+}
+
+// Webhook delivery states
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+type WebhookDelivery struct {
+	ID             string `json:"id"`
+	CreatedAt      string `json:"created_at"`
+	WebhookID      string `json:"webhook_id"`
+	EventType      string `json:"event_type"`
+	Payload        string `json:"payload"`
+	Status         string `json:"status"`
+	Attempts       int    `json:"attempts"`
+	NextAttemptAt  int64  `json:"next_attempt_at"`
+	ResponseStatus int    `json:"response_status"`
+	LastError      string `json:"last_error"`
+	DeliveredAt    string `json:"delivered_at"`
+	ClaimedUntil   int64  `json:"claimed_until"` // Unix seconds, while an instance is sending it
+}
+
+type NotificationPreferences struct {
+	UserID         string `json:"user_id"`
+	UpdatedAt      string `json:"updated_at"`
+	BookingChanges bool   `json:"booking_changes"` // Booking created, changed or cancelled
+	OwnChanges     bool   `json:"own_changes"`     // Also for changes made by the user
+	ArrivalsDigest bool   `json:"arrivals_digest"` // Daily list of tomorrow's arrivals
+}
+
+// Job run states
+const (
+	JobStatusRunning = "running"
+	JobStatusOK      = "ok"
+	JobStatusFailed  = "failed"
+)
+
+type JobStatus struct {
+	Name            string `json:"name"`
+	Schedule        string `json:"schedule"`
+	LockedBy        string `json:"locked_by"`
+	LockedUntil     int64  `json:"locked_until"`
+	LastScheduledAt int64  `json:"last_scheduled_at"`
+	LastStartedAt   string `json:"last_started_at"`
+	LastFinishedAt  string `json:"last_finished_at"`
+	LastStatus      string `json:"last_status"`
+	LastError       string `json:"last_error"`
+	LastDurationMs  int64  `json:"last_duration_ms"`
+	RunCount        int    `json:"run_count"`
+}
+
+// Stats are the totals reported as business metrics
+type Stats struct {
+	Users                int
+	Groups               int
+	Properties           int
+	Bookings             int
+	BookingsCreatedSince int // Bookings created after the time passed to GetStats
+}
+
+// IdempotencyKey is a POST request sent with an Idempotency-Key header, with the
+// response to replay when the client retries it
+type IdempotencyKey struct {
+	UserID      string // Empty on routes that don't require a session
+	Key         string
+	Fingerprint string // Hash of the method, path and body of the request
+	CreatedAt   int64
+	Status      int    // 0 while the request is being handled
+	Headers     string // JSON object of the response headers to replay
+	Body        []byte
+}
+
+// SearchResults are the matches of a group search, by entity type
+type SearchResults struct {
+	Bookings   []Booking
+	Guests     []Guest
+	Properties []Property
+}
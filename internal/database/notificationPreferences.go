@@ -0,0 +1,74 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+)
+
+func CreateNotificationPreferencesTable(db *sql.DB) error {
+	sqlStmt := `
+	create table if not exists notification_preferences (
+		user_id string not null primary key,
+		updated_at string,
+		booking_changes integer default 1,
+		own_changes integer default 0,
+		arrivals_digest integer default 1
+	);
+	`
+
+	_, err := db.Exec(sqlStmt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Service) GetNotificationPreferencesTableName() string {
+	return s.notificationPreferencesTable
+}
+
+// DefaultNotificationPreferences applies to users who never changed their preferences
+func DefaultNotificationPreferences(userID string) NotificationPreferences {
+	return NotificationPreferences{
+		UserID:         userID,
+		BookingChanges: true,
+		ArrivalsDigest: true,
+	}
+}
+
+func (s *Service) GetNotificationPreferences(userID string) (NotificationPreferences, error) {
+	defer s.lock("GetNotificationPreferences")()
+	var result NotificationPreferences
+	err := s.db.QueryRow("SELECT * FROM "+s.notificationPreferencesTable+" WHERE user_id = ?", userID).Scan(
+		&result.UserID,
+		&result.UpdatedAt,
+		&result.BookingChanges,
+		&result.OwnChanges,
+		&result.ArrivalsDigest)
+	if errors.Is(err, sql.ErrNoRows) {
+		return DefaultNotificationPreferences(userID), nil
+	}
+	if err != nil {
+		return NotificationPreferences{}, err
+	}
+	return result, nil
+}
+
+func (s *Service) UpsertNotificationPreferences(result NotificationPreferences) error {
+	defer s.lock("UpsertNotificationPreferences")()
+	_, err := s.db.Exec("INSERT INTO "+s.notificationPreferencesTable+
+		" (user_id, updated_at, booking_changes, own_changes, arrivals_digest) VALUES (?, ?, ?, ?, ?)"+
+		" ON CONFLICT (user_id) DO UPDATE SET updated_at = excluded.updated_at, booking_changes = excluded.booking_changes,"+
+		" own_changes = excluded.own_changes, arrivals_digest = excluded.arrivals_digest",
+		result.UserID,
+		result.UpdatedAt,
+		result.BookingChanges,
+		result.OwnChanges,
+		result.ArrivalsDigest)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
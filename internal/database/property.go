@@ -2,6 +2,7 @@ package database
 
 import (
 	"database/sql"
+	"strings"
 )
 
 func CreatePropertyTable(db *sql.DB) error {
@@ -13,7 +14,8 @@ func CreatePropertyTable(db *sql.DB) error {
 		created_at string,
 		group_id string not null,
 		name string not null,
-		color string
+		color string,
+		version integer not null default 1
 	);
 	`
 
@@ -26,6 +28,12 @@ func CreatePropertyTable(db *sql.DB) error {
 	// This will fail silently if the column already exists
 	_, _ = db.Exec(`ALTER TABLE properties ADD COLUMN color string DEFAULT '';`)
 
+	// Migration: Add the version for optimistic concurrency control
+	_, err = db.Exec(`ALTER TABLE properties ADD COLUMN version integer NOT NULL DEFAULT 1;`)
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
 	return nil
 }
 
@@ -34,8 +42,7 @@ func (s *Service) GetPropertyTableName() string {
 }
 
 func (s *Service) GetAllProperties() ([]Property, error) {
-	s.m.Lock()
-	defer s.m.Unlock()
+	defer s.lock("GetAllProperties")()
 	rows, err := s.db.Query("SELECT * FROM " + propertyTable)
 	if err != nil {
 		return nil, err
@@ -50,7 +57,8 @@ func (s *Service) GetAllProperties() ([]Property, error) {
 			&result.CreatedAt,
 			&result.GroupID,
 			&result.Name,
-			&result.Color); err != nil {
+			&result.Color,
+			&result.Version); err != nil {
 			return nil, err
 		}
 		results = append(results, result)
@@ -60,15 +68,15 @@ func (s *Service) GetAllProperties() ([]Property, error) {
 }
 
 func (s *Service) GetPropertyByID(id string) (Property, error) {
-	s.m.Lock()
-	defer s.m.Unlock()
+	defer s.lock("GetPropertyByID")()
 	var result Property
 	err := s.db.QueryRow("SELECT * FROM "+propertyTable+" WHERE id = ?", id).Scan(
 		&result.ID,
 		&result.CreatedAt,
 		&result.GroupID,
 		&result.Name,
-		&result.Color)
+		&result.Color,
+		&result.Version)
 	if err != nil {
 		return Property{}, err
 	}
@@ -76,8 +84,7 @@ func (s *Service) GetPropertyByID(id string) (Property, error) {
 }
 
 func (s *Service) InsertProperty(result Property) error {
-	s.m.Lock()
-	defer s.m.Unlock()
+	defer s.lock("InsertProperty")()
 	_, err := s.db.Exec("INSERT INTO "+propertyTable+
 		" (id, created_at, group_id, name, color) VALUES (?, ?, ?, ?, ?)",
 		result.ID,
@@ -93,8 +100,7 @@ func (s *Service) InsertProperty(result Property) error {
 }
 
 func (s *Service) GetPropertiesByGroupID(groupID string) ([]Property, error) {
-	s.m.Lock()
-	defer s.m.Unlock()
+	defer s.lock("GetPropertiesByGroupID")()
 	rows, err := s.db.Query("SELECT * FROM "+propertyTable+" WHERE group_id = ?", groupID)
 	if err != nil {
 		return nil, err
@@ -109,7 +115,8 @@ func (s *Service) GetPropertiesByGroupID(groupID string) ([]Property, error) {
 			&result.CreatedAt,
 			&result.GroupID,
 			&result.Name,
-			&result.Color); err != nil {
+			&result.Color,
+			&result.Version); err != nil {
 			return nil, err
 		}
 		results = append(results, result)
@@ -118,19 +125,62 @@ func (s *Service) GetPropertiesByGroupID(groupID string) ([]Property, error) {
 	return results, nil
 }
 
-func (s *Service) DeletePropertyByID(id string) error {
-	s.m.Lock()
-	defer s.m.Unlock()
-	_, err := s.db.Exec("DELETE FROM "+propertyTable+" WHERE id = ?", id)
+// DeletePropertyByID removes a property with its bookings and everything DeleteBooking
+// removes with a booking, in one transaction. It returns the bookings and attachments
+// removed, so the caller can announce the bookings and remove the attachments' blobs.
+// It returns ErrVersionConflict, and removes nothing, if the property isn't at the given version.
+func (s *Service) DeletePropertyByID(id string, version int) ([]Booking, []Attachment, error) {
+	defer s.lock("DeletePropertyByID")()
+
+	tx, err := s.db.Begin()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	return nil
+	defer tx.Rollback()
+
+	res, err := tx.Exec("DELETE FROM "+propertyTable+" WHERE id = ? AND version = ?", id, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := versionMatched(res); err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := tx.Query("SELECT * FROM "+s.bookingsTable+" WHERE property_id = ?", id)
+	if err != nil {
+		return nil, nil, err
+	}
+	bookings, err := scanBookings(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bookingIDs := "SELECT id FROM " + s.bookingsTable + " WHERE property_id = ?"
+	if _, err := tx.Exec("DELETE FROM "+s.guestRegistrationsTable+" WHERE booking_id IN ("+bookingIDs+")", id); err != nil {
+		return nil, nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM "+s.bookingCommentsTable+" WHERE booking_id IN ("+bookingIDs+")", id); err != nil {
+		return nil, nil, err
+	}
+	attachments, err := s.deleteAttachments(tx,
+		"(parent_type = 'booking' AND parent_id IN ("+bookingIDs+")) OR (parent_type = 'property' AND parent_id = ?)", id, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM "+s.bookingsTable+" WHERE property_id = ?", id); err != nil {
+		return nil, nil, err
+	}
+
+	return bookings, attachments, tx.Commit()
 }
 
-func (s *Service) UpdatePropertyColor(id string, color string) error {
-	s.m.Lock()
-	defer s.m.Unlock()
-	_, err := s.db.Exec("UPDATE "+propertyTable+" SET color = ? WHERE id = ?", color, id)
-	return err
+// UpdatePropertyColor changes the color of a property at the given version and
+// increments the version, or returns ErrVersionConflict
+func (s *Service) UpdatePropertyColor(id string, color string, version int) error {
+	defer s.lock("UpdatePropertyColor")()
+	res, err := s.db.Exec("UPDATE "+propertyTable+" SET color = ?, version = version + 1 WHERE id = ? AND version = ?", color, id, version)
+	if err != nil {
+		return err
+	}
+	return versionMatched(res)
 }
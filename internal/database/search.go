@@ -0,0 +1,387 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// driverName is the SQLite driver with the functions the queries use, such as search_rank
+const driverName = "sqlite3_booker"
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("search_rank", searchRank, true)
+		},
+	})
+}
+
+// Triggers keeping search_text up to date, named as those of the FTS5 index they replaced
+var searchTriggers = []string{
+	"bookings_search_insert", "bookings_search_update", "bookings_search_delete",
+	"guests_search_insert", "guests_search_update", "guests_search_delete",
+	"properties_search_insert", "properties_search_update", "properties_search_delete",
+}
+
+// The search_text table is an FTS4 index over the text of bookings (guest name and
+// notes), guests (name and notes) and properties (name), each row tagged with the
+// group it belongs to. Triggers on the indexed tables keep it up to date on every
+// insert, update and delete, whichever method makes the change. Accents are
+// folded, so "muller" finds "Müller".
+//
+// FTS4 is compiled into go-sqlite3 by default. When SQLite lacks it, as a system
+// library may, the index isn't created and searches fall back to matching
+// substrings, unranked and sensitive to accents.
+//
+// The index is FTS4 rather than FTS5, which go-sqlite3 only compiles with the
+// sqlite_fts5 build tag. FTS4 offers the same prefix queries, and searchRank ranks
+// its matches. Service only runs on SQLite, so there is no Postgres index. A
+// Postgres backend would need a tsvector column with a GIN index, ranked with ts_rank.
+func CreateSearchIndex(db *sql.DB) (bool, error) {
+	// Migration: Replace the FTS5 search_index, whose triggers make every change to the
+	// indexed tables fail in builds without FTS5
+	var legacy int
+	err := db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'search_index'").Scan(&legacy)
+	if err != nil {
+		return false, err
+	}
+	if legacy > 0 {
+		if err := dropSearchTriggers(db); err != nil {
+			return false, err
+		}
+		// Without FTS5 the table can't be dropped. Nothing writes to it any more.
+		_, err = db.Exec("DROP TABLE search_index")
+		if err != nil && !strings.Contains(err.Error(), "no such module") {
+			return false, err
+		}
+	}
+
+	var exists int
+	err = db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'search_text'").Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = db.Exec(`
+	create virtual table if not exists search_text using fts4 (
+		entity_type,
+		entity_id,
+		group_id,
+		title,
+		body,
+		notindexed=entity_type,
+		notindexed=entity_id,
+		notindexed=group_id,
+		tokenize=unicode61 "remove_diacritics=2"
+	);
+	`)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such module") {
+			slog.Warn("SQLite was built without FTS4, searches match substrings")
+			// Triggers writing to an index SQLite can't open would make every change fail
+			return false, dropSearchTriggers(db)
+		}
+		return false, err
+	}
+
+	sqlStmt := `
+	create trigger if not exists bookings_search_insert after insert on bookings begin
+		insert into search_text (entity_type, entity_id, group_id, title, body)
+		values ('booking', new.id, (select group_id from properties where id = new.property_id), new.guest_name, new.notes);
+	end;
+	create trigger if not exists bookings_search_update after update of property_id, guest_name, notes on bookings begin
+		delete from search_text where entity_type = 'booking' and entity_id = old.id;
+		insert into search_text (entity_type, entity_id, group_id, title, body)
+		values ('booking', new.id, (select group_id from properties where id = new.property_id), new.guest_name, new.notes);
+	end;
+	create trigger if not exists bookings_search_delete after delete on bookings begin
+		delete from search_text where entity_type = 'booking' and entity_id = old.id;
+	end;
+
+	create trigger if not exists guests_search_insert after insert on guests begin
+		insert into search_text (entity_type, entity_id, group_id, title, body)
+		values ('guest', new.id, new.group_id, new.name, new.notes);
+	end;
+	create trigger if not exists guests_search_update after update of name, notes on guests begin
+		delete from search_text where entity_type = 'guest' and entity_id = old.id;
+		insert into search_text (entity_type, entity_id, group_id, title, body)
+		values ('guest', new.id, new.group_id, new.name, new.notes);
+	end;
+	create trigger if not exists guests_search_delete after delete on guests begin
+		delete from search_text where entity_type = 'guest' and entity_id = old.id;
+	end;
+
+	create trigger if not exists properties_search_insert after insert on properties begin
+		insert into search_text (entity_type, entity_id, group_id, title, body)
+		values ('property', new.id, new.group_id, new.name, '');
+	end;
+	create trigger if not exists properties_search_update after update of name on properties begin
+		delete from search_text where entity_type = 'property' and entity_id = old.id;
+		insert into search_text (entity_type, entity_id, group_id, title, body)
+		values ('property', new.id, new.group_id, new.name, '');
+	end;
+	create trigger if not exists properties_search_delete after delete on properties begin
+		delete from search_text where entity_type = 'property' and entity_id = old.id;
+	end;
+	`
+	_, err = db.Exec(sqlStmt)
+	if err != nil {
+		return false, err
+	}
+
+	// Index the rows written before the index existed
+	if exists == 0 {
+		_, err = db.Exec(`
+		insert into search_text (entity_type, entity_id, group_id, title, body)
+		select 'booking', b.id, p.group_id, b.guest_name, b.notes from bookings b join properties p on p.id = b.property_id
+		union all
+		select 'guest', id, group_id, name, notes from guests
+		union all
+		select 'property', id, group_id, name, '' from properties;
+		`)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func dropSearchTriggers(db *sql.DB) error {
+	for _, trigger := range searchTriggers {
+		if _, err := db.Exec("DROP TRIGGER IF EXISTS " + trigger); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SearchGroup finds the bookings, guests and properties of a group matching every
+// word of query, at most limit of each, best matches first. Words match the start
+// of words in the text, so "mül" finds "Müller".
+func (s *Service) SearchGroup(groupID, query string, limit int) (SearchResults, error) {
+	defer s.lock("SearchGroup")()
+
+	var results SearchResults
+	words := strings.Fields(query)
+	if len(words) == 0 {
+		return results, nil
+	}
+
+	var err error
+	if s.fullTextSearch {
+		results.Bookings, err = s.matchBookings(groupID, words, limit)
+		if err != nil {
+			return SearchResults{}, err
+		}
+		results.Guests, err = s.matchGuests(groupID, words, limit)
+		if err != nil {
+			return SearchResults{}, err
+		}
+		results.Properties, err = s.matchProperties(groupID, words, limit)
+		if err != nil {
+			return SearchResults{}, err
+		}
+		return results, nil
+	}
+
+	results.Bookings, err = s.likeBookings(groupID, words, limit)
+	if err != nil {
+		return SearchResults{}, err
+	}
+	results.Guests, err = s.likeGuests(groupID, words, limit)
+	if err != nil {
+		return SearchResults{}, err
+	}
+	results.Properties, err = s.likeProperties(groupID, words, limit)
+	if err != nil {
+		return SearchResults{}, err
+	}
+	return results, nil
+}
+
+// matchQuery turns the words of a search into an FTS4 query matching rows with every
+// word as a prefix. Words are quoted, so operators typed by users are plain text.
+// FTS4 can't escape quotes within quotes, so they are dropped.
+func matchQuery(words []string) string {
+	terms := make([]string, len(words))
+	for i, word := range words {
+		terms[i] = `"` + strings.ReplaceAll(word, `"`, "") + `*"`
+	}
+	return strings.Join(terms, " ")
+}
+
+// Weights of the columns of search_text in searchRank: a match in the title counts ten
+// times one in the body, the unindexed columns count for nothing
+var searchWeights = []float64{0, 0, 0, 10, 1}
+
+// searchRank scores a match from matchinfo(search_text, 'pcx'), higher for better matches.
+// Each word's hits in a column count relative to its hits in that column over all rows,
+// so rare words weigh more, and are weighted by searchWeights. It is the rank function
+// of the SQLite FTS4 documentation.
+func searchRank(matchinfo []byte) float64 {
+	info := make([]uint32, len(matchinfo)/4)
+	for i := range info {
+		info[i] = binary.NativeEndian.Uint32(matchinfo[i*4:])
+	}
+	if len(info) < 2 {
+		return 0
+	}
+	phrases, columns := int(info[0]), int(info[1])
+	if len(info) < 2+3*phrases*columns {
+		return 0
+	}
+
+	var score float64
+	for p := range phrases {
+		for c := range min(columns, len(searchWeights)) {
+			hits := info[2+3*(p*columns+c):]
+			if hits[0] > 0 {
+				score += float64(hits[0]) / float64(hits[1]) * searchWeights[c]
+			}
+		}
+	}
+	return score
+}
+
+const searchOrder = "search_rank(matchinfo(search_text, 'pcx')) DESC"
+
+func (s *Service) matchBookings(groupID string, words []string, limit int) ([]Booking, error) {
+	rows, err := s.db.Query("SELECT b.* FROM search_text JOIN "+s.bookingsTable+" b ON b.id = search_text.entity_id"+
+		" WHERE search_text MATCH ? AND search_text.entity_type = 'booking' AND search_text.group_id = ?"+
+		" ORDER BY "+searchOrder+", b.start_date DESC LIMIT ?",
+		matchQuery(words), groupID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanBookings(rows)
+}
+
+func (s *Service) matchGuests(groupID string, words []string, limit int) ([]Guest, error) {
+	rows, err := s.db.Query("SELECT g.* FROM search_text JOIN "+s.guestsTable+" g ON g.id = search_text.entity_id"+
+		" WHERE search_text MATCH ? AND search_text.entity_type = 'guest' AND search_text.group_id = ?"+
+		" ORDER BY "+searchOrder+", g.name LIMIT ?",
+		matchQuery(words), groupID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanSearchGuests(rows)
+}
+
+func (s *Service) matchProperties(groupID string, words []string, limit int) ([]Property, error) {
+	rows, err := s.db.Query("SELECT p.* FROM search_text JOIN "+s.propertyTable+" p ON p.id = search_text.entity_id"+
+		" WHERE search_text MATCH ? AND search_text.entity_type = 'property' AND search_text.group_id = ?"+
+		" ORDER BY "+searchOrder+", p.name LIMIT ?",
+		matchQuery(words), groupID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanSearchProperties(rows)
+}
+
+// likeWords builds a condition matching rows where each word is in one of columns,
+// ignoring case, and its arguments. % and _ in words match only themselves.
+func likeWords(words []string, columns ...string) (string, []any) {
+	var conditions []string
+	var args []any
+	for _, word := range words {
+		pattern := containsPattern(strings.ToLower(word))
+		var matches []string
+		for _, column := range columns {
+			matches = append(matches, "lower("+column+") LIKE ? ESCAPE '\\'")
+			args = append(args, pattern)
+		}
+		conditions = append(conditions, "("+strings.Join(matches, " OR ")+")")
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+func (s *Service) likeBookings(groupID string, words []string, limit int) ([]Booking, error) {
+	condition, args := likeWords(words, "guest_name", "notes")
+	// Bookings with every word in the guest name first
+	title, titleArgs := likeWords(words, "guest_name")
+	args = append([]any{groupID}, args...)
+	args = append(args, titleArgs...)
+	rows, err := s.db.Query("SELECT * FROM "+s.bookingsTable+
+		" WHERE property_id IN (SELECT id FROM "+s.propertyTable+" WHERE group_id = ?) AND "+condition+
+		" ORDER BY ("+title+") DESC, start_date DESC LIMIT "+strconv.Itoa(limit),
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanBookings(rows)
+}
+
+func (s *Service) likeGuests(groupID string, words []string, limit int) ([]Guest, error) {
+	condition, args := likeWords(words, "name", "notes")
+	title, titleArgs := likeWords(words, "name")
+	args = append([]any{groupID}, args...)
+	args = append(args, titleArgs...)
+	rows, err := s.db.Query("SELECT * FROM "+s.guestsTable+
+		" WHERE group_id = ? AND "+condition+
+		" ORDER BY ("+title+") DESC, name LIMIT "+strconv.Itoa(limit),
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanSearchGuests(rows)
+}
+
+func (s *Service) likeProperties(groupID string, words []string, limit int) ([]Property, error) {
+	condition, args := likeWords(words, "name")
+	args = append([]any{groupID}, args...)
+	rows, err := s.db.Query("SELECT * FROM "+s.propertyTable+
+		" WHERE group_id = ? AND "+condition+
+		" ORDER BY name LIMIT "+strconv.Itoa(limit),
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanSearchProperties(rows)
+}
+
+func scanSearchGuests(rows *sql.Rows) ([]Guest, error) {
+	defer rows.Close()
+	var results []Guest
+	for rows.Next() {
+		var result Guest
+		if err := rows.Scan(
+			&result.ID,
+			&result.CreatedAt,
+			&result.GroupID,
+			&result.Name,
+			&result.Email,
+			&result.Phone,
+			&result.Country,
+			&result.DocumentNumber,
+			&result.Notes); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+func scanSearchProperties(rows *sql.Rows) ([]Property, error) {
+	defer rows.Close()
+	var results []Property
+	for rows.Next() {
+		var result Property
+		if err := rows.Scan(
+			&result.ID,
+			&result.CreatedAt,
+			&result.GroupID,
+			&result.Name,
+			&result.Color,
+			&result.Version); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,23 @@
+package database
+
+// GetStats counts the users, groups, properties and bookings, and the bookings
+// created since a unix time
+func (s *Service) GetStats(since int64) (Stats, error) {
+	defer s.lock("GetStats")()
+	var result Stats
+	err := s.db.QueryRow("SELECT "+
+		"(SELECT count(*) FROM "+s.usersTable+"), "+
+		"(SELECT count(*) FROM "+s.groupsTable+"), "+
+		"(SELECT count(*) FROM "+s.propertyTable+"), "+
+		"(SELECT count(*) FROM "+s.bookingsTable+"), "+
+		"(SELECT count(*) FROM "+s.bookingsTable+" WHERE CAST(created_at AS INTEGER) >= ?)", since).Scan(
+		&result.Users,
+		&result.Groups,
+		&result.Properties,
+		&result.Bookings,
+		&result.BookingsCreatedSince)
+	if err != nil {
+		return Stats{}, err
+	}
+	return result, nil
+}
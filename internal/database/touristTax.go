@@ -0,0 +1,138 @@
+package database
+
+import (
+	"database/sql"
+)
+
+func CreateTouristTaxRulesTable(db *sql.DB) error {
+	sqlStmt := `
+	create table if not exists tourist_tax_rules (
+		id string not null primary key,
+		created_at string,
+		group_id string not null,
+		property_id string default '',
+		adult_rate real default 0,
+		child_rate real default 0,
+		child_under_age integer default 18,
+		exempt_under_age integer default 0,
+		currency string default 'EUR',
+		valid_from string default '',
+		valid_to string default ''
+	);
+	`
+
+	_, err := db.Exec(sqlStmt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Service) GetTouristTaxRulesTableName() string {
+	return s.touristTaxRulesTable
+}
+
+func (s *Service) GetTouristTaxRuleByID(id string) (TouristTaxRule, error) {
+	defer s.lock("GetTouristTaxRuleByID")()
+	var result TouristTaxRule
+	err := s.db.QueryRow("SELECT * FROM "+s.touristTaxRulesTable+" WHERE id = ?", id).Scan(
+		&result.ID,
+		&result.CreatedAt,
+		&result.GroupID,
+		&result.PropertyID,
+		&result.AdultRate,
+		&result.ChildRate,
+		&result.ChildUnderAge,
+		&result.ExemptUnderAge,
+		&result.Currency,
+		&result.ValidFrom,
+		&result.ValidTo)
+	if err != nil {
+		return TouristTaxRule{}, err
+	}
+	return result, nil
+}
+
+func (s *Service) GetTouristTaxRulesByGroupID(groupID string) ([]TouristTaxRule, error) {
+	defer s.lock("GetTouristTaxRulesByGroupID")()
+
+	rows, err := s.db.Query("SELECT * FROM "+s.touristTaxRulesTable+" WHERE group_id = ? ORDER BY valid_from", groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []TouristTaxRule
+	for rows.Next() {
+		var result TouristTaxRule
+		if err := rows.Scan(
+			&result.ID,
+			&result.CreatedAt,
+			&result.GroupID,
+			&result.PropertyID,
+			&result.AdultRate,
+			&result.ChildRate,
+			&result.ChildUnderAge,
+			&result.ExemptUnderAge,
+			&result.Currency,
+			&result.ValidFrom,
+			&result.ValidTo); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func (s *Service) InsertTouristTaxRule(result TouristTaxRule) error {
+	defer s.lock("InsertTouristTaxRule")()
+	_, err := s.db.Exec("INSERT INTO "+s.touristTaxRulesTable+
+		" (id, created_at, group_id, property_id, adult_rate, child_rate, child_under_age, exempt_under_age, currency, valid_from, valid_to) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		result.ID,
+		result.CreatedAt,
+		result.GroupID,
+		result.PropertyID,
+		result.AdultRate,
+		result.ChildRate,
+		result.ChildUnderAge,
+		result.ExemptUnderAge,
+		result.Currency,
+		result.ValidFrom,
+		result.ValidTo)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Service) UpdateTouristTaxRule(result TouristTaxRule) error {
+	defer s.lock("UpdateTouristTaxRule")()
+	_, err := s.db.Exec("UPDATE "+s.touristTaxRulesTable+
+		" SET property_id = ?, adult_rate = ?, child_rate = ?, child_under_age = ?, exempt_under_age = ?, currency = ?, valid_from = ?, valid_to = ? WHERE id = ?",
+		result.PropertyID,
+		result.AdultRate,
+		result.ChildRate,
+		result.ChildUnderAge,
+		result.ExemptUnderAge,
+		result.Currency,
+		result.ValidFrom,
+		result.ValidTo,
+		result.ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Service) DeleteTouristTaxRule(id string) error {
+	defer s.lock("DeleteTouristTaxRule")()
+	_, err := s.db.Exec("DELETE FROM "+s.touristTaxRulesTable+" WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -2,7 +2,9 @@ package database
 
 import (
 	"database/sql"
-	"fmt"
+	"errors"
+	"log/slog"
+	"strings"
 )
 
 func CreateUsersTable(db *sql.DB) error {
@@ -12,7 +14,8 @@ func CreateUsersTable(db *sql.DB) error {
 	create table if not exists users (
 		id string not null primary key,
 		username string not null,
-		hashed_password string not null
+		hashed_password string not null,
+		email string default ''
 	);
 	`
 
@@ -21,6 +24,12 @@ func CreateUsersTable(db *sql.DB) error {
 		return err
 	}
 
+	// Migration: Add the email address used for notifications
+	_, err = db.Exec(`ALTER TABLE users ADD COLUMN email string DEFAULT '';`)
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
 	return nil
 }
 
@@ -29,13 +38,13 @@ func (s *Service) GetUsersTableName() string {
 }
 
 func (s *Service) GetUserByID(id string) (User, error) {
-	s.m.Lock()
-	defer s.m.Unlock()
+	defer s.lock("GetUserByID")()
 	var result User
 	err := s.db.QueryRow("SELECT * FROM "+s.usersTable+" WHERE id = ?", id).Scan(
 		&result.ID,
 		&result.Username,
-		&result.HashedPassword)
+		&result.HashedPassword,
+		&result.Email)
 	if err != nil {
 		return User{}, err
 	}
@@ -43,29 +52,43 @@ func (s *Service) GetUserByID(id string) (User, error) {
 }
 
 func (s *Service) GetUserByUsername(username string) (User, error) {
-	s.m.Lock()
-	defer s.m.Unlock()
+	defer s.lock("GetUserByUsername")()
 	var result User
 	err := s.db.QueryRow("SELECT * FROM "+s.usersTable+" WHERE username = ?", username).Scan(
 		&result.ID,
 		&result.Username,
-		&result.HashedPassword)
+		&result.HashedPassword,
+		&result.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		slog.DebugContext(s.context(), "User not found", "username", username)
+		return User{}, err
+	}
 	if err != nil {
-		fmt.Println("Error retrieving user by username:", err)
+		slog.ErrorContext(s.context(), "Error retrieving user by username", "username", username, "error", err)
 		return User{}, err
 	}
 	return result, nil
 }
 
 func (s *Service) InsertUser(result User) error {
-	s.m.Lock()
-	defer s.m.Unlock()
+	defer s.lock("InsertUser")()
 	_, err := s.db.Exec("INSERT INTO "+s.usersTable+
-		" (id, username, hashed_password) VALUES (?, ?, ?)",
+		" (id, username, hashed_password, email) VALUES (?, ?, ?, ?)",
 		result.ID,
 		result.Username,
-		result.HashedPassword)
+		result.HashedPassword,
+		result.Email)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
 
+func (s *Service) UpdateUserEmail(id, email string) error {
+	defer s.lock("UpdateUserEmail")()
+	_, err := s.db.Exec("UPDATE "+s.usersTable+" SET email = ? WHERE id = ?", email, id)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,23 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// ErrVersionConflict is returned when a booking, property or group was changed since the
+// version the caller read. Every change increments the version of the row.
+var ErrVersionConflict = errors.New("version conflict")
+
+// versionMatched turns the result of a statement conditioned on the version into ErrVersionConflict
+// when no row was affected
+func versionMatched(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
@@ -0,0 +1,295 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+)
+
+func CreateWebhooksTable(db *sql.DB) error {
+	sqlStmt := `
+	create table if not exists webhooks (
+		id string not null primary key,
+		created_at string,
+		created_by string,
+		group_id string not null,
+		url string not null,
+		event_types string not null,
+		secret string not null,
+		active integer default 1
+	);
+	`
+
+	_, err := db.Exec(sqlStmt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func CreateWebhookDeliveriesTable(db *sql.DB) error {
+	sqlStmt := `
+	create table if not exists webhook_deliveries (
+		id string not null primary key,
+		created_at string,
+		webhook_id string not null,
+		event_type string not null,
+		payload string not null,
+		status string not null,
+		attempts integer default 0,
+		next_attempt_at integer default 0,
+		response_status integer default 0,
+		last_error string default '',
+		delivered_at string default '',
+		claimed_until integer default 0
+	);
+	create index if not exists webhook_deliveries_due on webhook_deliveries (status, next_attempt_at);
+	`
+
+	_, err := db.Exec(sqlStmt)
+	if err != nil {
+		return err
+	}
+
+	// Migration: Add the claim that keeps other instances from sending a delivery
+	_, err = db.Exec(`ALTER TABLE webhook_deliveries ADD COLUMN claimed_until integer DEFAULT 0;`)
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Service) GetWebhooksTableName() string {
+	return s.webhooksTable
+}
+
+func (s *Service) GetWebhookDeliveriesTableName() string {
+	return s.webhookDeliveriesTable
+}
+
+func (s *Service) GetWebhookByID(id string) (Webhook, error) {
+	defer s.lock("GetWebhookByID")()
+	var result Webhook
+	var eventTypes string
+	err := s.db.QueryRow("SELECT * FROM "+s.webhooksTable+" WHERE id = ?", id).Scan(
+		&result.ID,
+		&result.CreatedAt,
+		&result.CreatedBy,
+		&result.GroupID,
+		&result.URL,
+		&eventTypes,
+		&result.Secret,
+		&result.Active)
+	if err != nil {
+		return Webhook{}, err
+	}
+	result.EventTypes = splitEventTypes(eventTypes)
+	return result, nil
+}
+
+func (s *Service) GetWebhooksByGroupID(groupID string) ([]Webhook, error) {
+	defer s.lock("GetWebhooksByGroupID")()
+
+	rows, err := s.db.Query("SELECT * FROM "+s.webhooksTable+" WHERE group_id = ? ORDER BY created_at", groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []Webhook
+	for rows.Next() {
+		var result Webhook
+		var eventTypes string
+		if err := rows.Scan(
+			&result.ID,
+			&result.CreatedAt,
+			&result.CreatedBy,
+			&result.GroupID,
+			&result.URL,
+			&eventTypes,
+			&result.Secret,
+			&result.Active); err != nil {
+			return nil, err
+		}
+		result.EventTypes = splitEventTypes(eventTypes)
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func (s *Service) InsertWebhook(result Webhook) error {
+	defer s.lock("InsertWebhook")()
+	_, err := s.db.Exec("INSERT INTO "+s.webhooksTable+
+		" (id, created_at, created_by, group_id, url, event_types, secret, active) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		result.ID,
+		result.CreatedAt,
+		result.CreatedBy,
+		result.GroupID,
+		result.URL,
+		strings.Join(result.EventTypes, ","),
+		result.Secret,
+		result.Active)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Service) UpdateWebhook(result Webhook) error {
+	defer s.lock("UpdateWebhook")()
+	_, err := s.db.Exec("UPDATE "+s.webhooksTable+" SET url = ?, event_types = ?, secret = ?, active = ? WHERE id = ?",
+		result.URL,
+		strings.Join(result.EventTypes, ","),
+		result.Secret,
+		result.Active,
+		result.ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteWebhook removes a webhook together with its delivery log
+func (s *Service) DeleteWebhook(id string) error {
+	defer s.lock("DeleteWebhook")()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM "+s.webhookDeliveriesTable+" WHERE webhook_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM "+s.webhooksTable+" WHERE id = ?", id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetWebhookDeliveriesByWebhookID returns the most recent deliveries of a webhook, newest first
+func (s *Service) GetWebhookDeliveriesByWebhookID(webhookID string, limit int) ([]WebhookDelivery, error) {
+	defer s.lock("GetWebhookDeliveriesByWebhookID")()
+
+	rows, err := s.db.Query("SELECT * FROM "+s.webhookDeliveriesTable+" WHERE webhook_id = ? ORDER BY created_at DESC, rowid DESC LIMIT ?", webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanWebhookDeliveries(rows)
+}
+
+// GetDueWebhookDeliveries returns pending deliveries whose next attempt is due and
+// that no instance has claimed, oldest first
+func (s *Service) GetDueWebhookDeliveries(now int64, limit int) ([]WebhookDelivery, error) {
+	defer s.lock("GetDueWebhookDeliveries")()
+
+	rows, err := s.db.Query("SELECT * FROM "+s.webhookDeliveriesTable+
+		" WHERE status = ? AND next_attempt_at <= ? AND claimed_until < ? ORDER BY next_attempt_at, rowid LIMIT ?",
+		WebhookDeliveryPending, now, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanWebhookDeliveries(rows)
+}
+
+func (s *Service) InsertWebhookDelivery(result WebhookDelivery) error {
+	defer s.lock("InsertWebhookDelivery")()
+	_, err := s.db.Exec("INSERT INTO "+s.webhookDeliveriesTable+
+		" (id, created_at, webhook_id, event_type, payload, status, attempts, next_attempt_at, response_status, last_error, delivered_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		result.ID,
+		result.CreatedAt,
+		result.WebhookID,
+		result.EventType,
+		result.Payload,
+		result.Status,
+		result.Attempts,
+		result.NextAttemptAt,
+		result.ResponseStatus,
+		result.LastError,
+		result.DeliveredAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ClaimWebhookDelivery claims a due delivery until the given time (unix seconds), so
+// that when several instances share the database only one of them sends it. It
+// fails when the delivery is no longer due or another claim hasn't expired.
+func (s *Service) ClaimWebhookDelivery(id string, now, until int64) (bool, error) {
+	defer s.lock("ClaimWebhookDelivery")()
+	res, err := s.db.Exec("UPDATE "+s.webhookDeliveriesTable+
+		" SET claimed_until = ? WHERE id = ? AND status = ? AND next_attempt_at <= ? AND claimed_until < ?",
+		until, id, WebhookDeliveryPending, now, now)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// UpdateWebhookDeliveryAttempt records the outcome of a delivery attempt and releases its claim
+func (s *Service) UpdateWebhookDeliveryAttempt(result WebhookDelivery) error {
+	defer s.lock("UpdateWebhookDeliveryAttempt")()
+	_, err := s.db.Exec("UPDATE "+s.webhookDeliveriesTable+
+		" SET status = ?, attempts = ?, next_attempt_at = ?, response_status = ?, last_error = ?, delivered_at = ?, claimed_until = 0 WHERE id = ?",
+		result.Status,
+		result.Attempts,
+		result.NextAttemptAt,
+		result.ResponseStatus,
+		result.LastError,
+		result.DeliveredAt,
+		result.ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func scanWebhookDeliveries(rows *sql.Rows) ([]WebhookDelivery, error) {
+	var results []WebhookDelivery
+	for rows.Next() {
+		var result WebhookDelivery
+		if err := rows.Scan(
+			&result.ID,
+			&result.CreatedAt,
+			&result.WebhookID,
+			&result.EventType,
+			&result.Payload,
+			&result.Status,
+			&result.Attempts,
+			&result.NextAttemptAt,
+			&result.ResponseStatus,
+			&result.LastError,
+			&result.DeliveredAt,
+			&result.ClaimedUntil); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func splitEventTypes(eventTypes string) []string {
+	if eventTypes == "" {
+		return []string{}
+	}
+	return strings.Split(eventTypes, ",")
+}
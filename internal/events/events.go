@@ -0,0 +1,226 @@
+package events
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Event types published by the server handlers
+const (
+	BookingCreated  = "booking.created"
+	BookingUpdated  = "booking.updated"
+	BookingDeleted  = "booking.deleted"
+	PropertyCreated = "property.created"
+	PropertyUpdated = "property.updated"
+	PropertyDeleted = "property.deleted"
+
+	// Reset tells a resuming subscriber that events were missed and it should reload
+	Reset = "reset"
+)
+
+const subscriberBuffer = 64
+
+// Event is a change to a resource of a group
+type Event struct {
+	ID      uint64    `json:"id"`
+	Type    string    `json:"type"`
+	GroupID string    `json:"group_id"`
+	ActorID string    `json:"actor_id"`
+	Time    time.Time `json:"time"`
+	Data    any       `json:"data"` // The resource after the change, as the API returns it
+
+	// Changes lists the fields an update changed, keyed by their JSON name
+	Changes map[string]Change `json:"changes,omitempty"`
+}
+
+// Change is the previous and new value of a field
+type Change struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// Hub is an in-process publish/subscribe hub for group events.
+// It keeps the most recent events of every group so subscribers can resume
+// after a reconnect.
+type Hub struct {
+	mu          sync.Mutex
+	firstID     uint64 // IDs up to firstID were issued before this hub existed
+	nextID      uint64
+	historySize int
+	history     map[string][]Event                    // group ID -> recent events, oldest first
+	trimmed     map[string]uint64                     // group ID -> ID of the newest event dropped from history
+	subscribers map[string]map[*Subscription]struct{} // group ID -> subscribers
+	listeners   []func(Event)
+	closed      bool
+}
+
+// Subscription receives the events of one group on C.
+// C is closed when the subscription is closed, when the hub shuts down,
+// or when the subscriber falls too far behind.
+type Subscription struct {
+	C <-chan Event
+
+	c       chan Event
+	hub     *Hub
+	groupID string
+}
+
+// NewHub creates a hub remembering up to historySize events per group
+func NewHub(historySize int) *Hub {
+	// Seed IDs from the clock so they keep growing across restarts and a
+	// Last-Event-ID from a previous process is never mistaken for a current one
+	firstID := uint64(time.Now().UnixMilli()) * 1000
+	return &Hub{
+		firstID:     firstID,
+		nextID:      firstID,
+		historySize: historySize,
+		history:     make(map[string][]Event),
+		trimmed:     make(map[string]uint64),
+		subscribers: make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+// Publish assigns the event an ID and timestamp, records it and delivers it to
+// the group's subscribers. Subscribers that cannot keep up are dropped.
+// Listeners are then called with the event before Publish returns.
+func (h *Hub) Publish(e Event) Event {
+	h.mu.Lock()
+	h.nextID++
+	e.ID = h.nextID
+	e.Time = time.Now().UTC()
+
+	history := append(h.history[e.GroupID], e)
+	if len(history) > h.historySize {
+		drop := len(history) - h.historySize
+		h.trimmed[e.GroupID] = history[drop-1].ID
+		history = history[drop:]
+	}
+	h.history[e.GroupID] = history
+
+	for sub := range h.subscribers[e.GroupID] {
+		select {
+		case sub.c <- e:
+		default:
+			h.remove(sub)
+		}
+	}
+	listeners := h.listeners
+	h.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(e)
+	}
+	return e
+}
+
+// OnPublish registers a function called with every published event of every group.
+// It runs in the publisher's goroutine, so work that may be slow belongs elsewhere.
+func (h *Hub) OnPublish(fn func(Event)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.listeners = append(h.listeners[:len(h.listeners):len(h.listeners)], fn)
+}
+
+// Subscribe starts receiving the events of a group. If lastEventID is not zero,
+// the events published after it are returned for replay; when some of them are
+// no longer remembered a single Reset event is returned instead.
+func (h *Hub) Subscribe(groupID string, lastEventID uint64) (*Subscription, []Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c := make(chan Event, subscriberBuffer)
+	sub := &Subscription{C: c, c: c, hub: h, groupID: groupID}
+	if h.closed {
+		close(c)
+		return sub, nil
+	}
+
+	if h.subscribers[groupID] == nil {
+		h.subscribers[groupID] = make(map[*Subscription]struct{})
+	}
+	h.subscribers[groupID][sub] = struct{}{}
+
+	if lastEventID == 0 {
+		return sub, nil
+	}
+	return sub, h.missed(groupID, lastEventID)
+}
+
+// Close stops the subscription and closes its channel
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// Close shuts the hub down, closing every subscription. Events published
+// afterwards still reach the listeners, but no longer any subscribers.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for _, subs := range h.subscribers {
+		for sub := range subs {
+			h.remove(sub)
+		}
+	}
+}
+
+// missed returns the remembered events of a group published after lastEventID
+func (h *Hub) missed(groupID string, lastEventID uint64) []Event {
+	// Events may have been lost if the ID predates this hub, is from the future
+	// (e.g. after a clock change), or newer events were already dropped from history
+	if lastEventID < h.firstID || lastEventID > h.nextID || lastEventID < h.trimmed[groupID] {
+		return []Event{{ID: h.nextID, Type: Reset, GroupID: groupID, Time: time.Now().UTC()}}
+	}
+
+	var missed []Event
+	for _, e := range h.history[groupID] {
+		if e.ID > lastEventID {
+			missed = append(missed, e)
+		}
+	}
+	return missed
+}
+
+// remove drops a subscriber and closes its channel. h.mu must be held.
+func (h *Hub) remove(sub *Subscription) {
+	subs := h.subscribers[sub.groupID]
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.subscribers, sub.groupID)
+	}
+	close(sub.c)
+}
+
+// Diff compares the JSON objects of two values and returns the fields that differ
+func Diff(before, after any) map[string]Change {
+	from, to := jsonFields(before), jsonFields(after)
+	changes := make(map[string]Change)
+	for k, v := range to {
+		if !reflect.DeepEqual(from[k], v) {
+			changes[k] = Change{From: from[k], To: v}
+		}
+	}
+	for k, v := range from {
+		if _, ok := to[k]; !ok {
+			changes[k] = Change{From: v}
+		}
+	}
+	return changes
+}
+
+func jsonFields(v any) map[string]any {
+	fields := make(map[string]any)
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fields
+	}
+	_ = json.Unmarshal(b, &fields)
+	return fields
+}
@@ -0,0 +1,302 @@
+package jobs
+
+import (
+	"booker-be/internal/database"
+	"booker-be/internal/logging"
+	"booker-be/internal/metrics"
+	"booker-be/internal/protocol"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTimeout = 10 * time.Minute
+	maxErrorLength = 500
+)
+
+var (
+	jobRuns     = metrics.Default.NewCounter("booker_job_runs_total", "Background job runs on this instance, by job and outcome.", "job", "status")
+	jobDuration = metrics.Default.NewHistogram("booker_job_duration_seconds", "Duration of background job runs, by job.", []float64{.01, .1, 1, 10, 60, 300, 900}, "job")
+	jobLastOK   = metrics.Default.NewGauge("booker_job_last_success_timestamp_seconds", "Unix time of the last successful run of a job on this instance.", "job")
+)
+
+var (
+	ErrUnknownJob     = errors.New("unknown job")
+	ErrAlreadyRunning = errors.New("job is already running")
+	ErrLeased         = errors.New("job is running on another instance")
+	ErrNotStarted     = errors.New("scheduler is not started")
+)
+
+// Job is a named task run on a schedule
+type Job struct {
+	Name     string
+	Schedule Schedule
+	Run      func(ctx context.Context) error
+
+	// Timeout cancels a run that takes longer; it is also how long the run's lease
+	// is held, after which another instance may take over. Defaults to 10 minutes.
+	Timeout time.Duration
+
+	// Local jobs look after state of this process, such as the in-memory sessions,
+	// so they run on every instance instead of on only one of them
+	Local bool
+}
+
+// Status is the state of a job as shown to administrators
+type Status struct {
+	database.JobStatus
+	Local     bool   `json:"local"`
+	NextRunAt string `json:"next_run_at"`
+}
+
+// Scheduler runs jobs on their schedules until its context is cancelled.
+// A job never runs twice at once on an instance, whether scheduled or triggered.
+// Runs are recorded in the database, whose leases keep other instances from running
+// a non-local job at the same time, and make sure each scheduled run happens on
+// one instance only.
+type Scheduler struct {
+	db         database.Service
+	instanceID string
+
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	order   []string
+	nextRun map[string]time.Time
+	active  map[string]bool // Jobs with a run in progress on this instance
+	ctx     context.Context
+	running sync.WaitGroup
+}
+
+func NewScheduler(db database.Service) *Scheduler {
+	host, _ := os.Hostname()
+	return &Scheduler{
+		db:         db,
+		instanceID: fmt.Sprintf("%s/%d/%s", host, os.Getpid(), protocol.GenerateID()[:8]),
+		jobs:       make(map[string]*Job),
+		nextRun:    make(map[string]time.Time),
+		active:     make(map[string]bool),
+	}
+}
+
+// Add registers a job. Jobs must be added before Start.
+func (s *Scheduler) Add(job Job) error {
+	if job.Name == "" || job.Schedule == nil || job.Run == nil {
+		return fmt.Errorf("job %q needs a name, a schedule and a run function", job.Name)
+	}
+	if job.Timeout <= 0 {
+		job.Timeout = defaultTimeout
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[job.Name]; ok {
+		return fmt.Errorf("job %q is already registered", job.Name)
+	}
+	if err := s.db.EnsureJob(job.Name, job.Schedule.String()); err != nil {
+		return err
+	}
+	s.jobs[job.Name] = &job
+	s.order = append(s.order, job.Name)
+	return nil
+}
+
+// Start runs every job on its schedule until ctx is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ctx = ctx
+	for _, name := range s.order {
+		job := s.jobs[name]
+		s.running.Add(1)
+		go func() {
+			defer s.running.Done()
+			s.loop(ctx, job)
+		}()
+	}
+}
+
+// Running reports whether the scheduler has been started and not yet stopped
+func (s *Scheduler) Running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ctx != nil && s.ctx.Err() == nil
+}
+
+// Wait blocks until the scheduler's context is cancelled and the runs in progress have finished
+func (s *Scheduler) Wait() {
+	s.running.Wait()
+}
+
+// Trigger starts a run of a job right away, outside its schedule
+func (s *Scheduler) Trigger(name string) error {
+	s.mu.Lock()
+	job, ok := s.jobs[name]
+	ctx := s.ctx
+	s.mu.Unlock()
+	if !ok {
+		return ErrUnknownJob
+	}
+	if ctx == nil || ctx.Err() != nil {
+		return ErrNotStarted
+	}
+
+	if !s.begin(job) {
+		return ErrAlreadyRunning
+	}
+	now := time.Now()
+	leased, err := s.lease(job, now)
+	if err != nil || !leased {
+		s.end(job)
+		if err != nil {
+			return err
+		}
+		return ErrLeased
+	}
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		defer s.end(job)
+		s.execute(ctx, job, now)
+	}()
+	return nil
+}
+
+// Statuses returns the state of every registered job
+func (s *Scheduler) Statuses() ([]Status, error) {
+	recorded, err := s.db.GetAllJobs()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]Status, 0, len(s.order))
+	for _, r := range recorded {
+		job, ok := s.jobs[r.Name]
+		if !ok {
+			// Registered by another version of the server
+			continue
+		}
+		status := Status{JobStatus: r, Local: job.Local}
+		if next, ok := s.nextRun[r.Name]; ok {
+			status.NextRunAt = fmt.Sprintf("%d", next.Unix())
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (s *Scheduler) loop(ctx context.Context, job *Job) {
+	for {
+		next := job.Schedule.Next(time.Now())
+		if next.IsZero() {
+			slog.Warn("Job has no upcoming runs", "job", job.Name)
+			return
+		}
+		s.mu.Lock()
+		s.nextRun[job.Name] = next
+		s.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if !s.begin(job) {
+			slog.Warn("Skipping a scheduled run of a job that is still running", "job", job.Name)
+			continue
+		}
+		started, err := s.claim(job, next, time.Now())
+		if err != nil {
+			slog.Error("Error starting job", "job", job.Name, "error", err)
+		} else if started {
+			s.execute(ctx, job, time.Now())
+		}
+		s.end(job)
+	}
+}
+
+// begin marks a job as running on this instance, and returns false when it already is
+func (s *Scheduler) begin(job *Job) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active[job.Name] {
+		return false
+	}
+	s.active[job.Name] = true
+	return true
+}
+
+// end marks a run started with begin as finished
+func (s *Scheduler) end(job *Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.active, job.Name)
+}
+
+// claim reports whether this instance should run the job for the slot
+func (s *Scheduler) claim(job *Job, slot, now time.Time) (bool, error) {
+	if job.Local {
+		return true, nil
+	}
+	return s.db.AcquireJobLease(job.Name, s.instanceID, slot.Unix(), now.Unix(), now.Add(job.Timeout).Unix(), fmt.Sprintf("%d", now.Unix()))
+}
+
+// lease reports whether this instance may run the job now, outside its schedule.
+// Only other instances holding the lease stop it; runs on this one are tracked by begin.
+func (s *Scheduler) lease(job *Job, now time.Time) (bool, error) {
+	if job.Local {
+		return true, nil
+	}
+	return s.db.AcquireTriggeredJobLease(job.Name, s.instanceID, now.Unix(), now.Add(job.Timeout).Unix(), fmt.Sprintf("%d", now.Unix()))
+}
+
+// execute runs the job once and records the outcome. A failing or panicking
+// job is logged and retried at its next scheduled time.
+func (s *Scheduler) execute(ctx context.Context, job *Job, started time.Time) {
+	runCtx, cancel := context.WithTimeout(logging.With(ctx, "job", job.Name), job.Timeout)
+	defer cancel()
+
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return job.Run(runCtx)
+	}()
+
+	finished := time.Now()
+	result := database.JobStatus{
+		Name:           job.Name,
+		LastStartedAt:  fmt.Sprintf("%d", started.Unix()),
+		LastFinishedAt: fmt.Sprintf("%d", finished.Unix()),
+		LastStatus:     database.JobStatusOK,
+		LastDurationMs: finished.Sub(started).Milliseconds(),
+	}
+	if err != nil {
+		slog.ErrorContext(runCtx, "Job failed", "duration_ms", result.LastDurationMs, "error", err)
+		result.LastStatus = database.JobStatusFailed
+		result.LastError = err.Error()
+		if len(result.LastError) > maxErrorLength {
+			result.LastError = result.LastError[:maxErrorLength]
+		}
+	} else {
+		slog.InfoContext(runCtx, "Job finished", "duration_ms", result.LastDurationMs)
+		jobLastOK.Set(float64(finished.Unix()), job.Name)
+	}
+	jobRuns.Inc(job.Name, result.LastStatus)
+	jobDuration.Observe(finished.Sub(started).Seconds(), job.Name)
+	if err := s.db.FinishJobRun(result); err != nil {
+		slog.ErrorContext(runCtx, "Error recording job run", "error", err)
+	}
+}
@@ -0,0 +1,117 @@
+package jobs
+
+import (
+	"booker-be/internal/database"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// blockingJob runs until release is closed, and reports on done each time it finishes
+func blockingJob(name string, local bool) (job Job, release chan struct{}, done chan struct{}) {
+	release = make(chan struct{})
+	done = make(chan struct{}, 1)
+	job = Job{
+		Name:     name,
+		Schedule: Every(time.Hour),
+		Local:    local,
+		Run: func(ctx context.Context) error {
+			<-release
+			done <- struct{}{}
+			return nil
+		},
+	}
+	return job, release, done
+}
+
+func startScheduler(t *testing.T, db database.Service, jobs ...Job) *Scheduler {
+	t.Helper()
+	s := NewScheduler(db)
+	for _, job := range jobs {
+		if err := s.Add(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+	t.Cleanup(func() {
+		cancel()
+		s.Wait()
+	})
+	return s
+}
+
+// waitIdle waits until the scheduler has marked the job's run as finished
+func waitIdle(t *testing.T, s *Scheduler, name string) {
+	t.Helper()
+	for range 100 {
+		s.mu.Lock()
+		active := s.active[name]
+		s.mu.Unlock()
+		if !active {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s is still running", name)
+}
+
+func TestTriggerRefusesOverlappingRuns(t *testing.T) {
+	db := database.New(filepath.Join(t.TempDir(), "bookings.db"))
+	t.Cleanup(func() { db.Close() })
+
+	for _, local := range []bool{true, false} {
+		name := "leased"
+		if local {
+			name = "local"
+		}
+		t.Run(name, func(t *testing.T) {
+			job, release, done := blockingJob(name, local)
+			s := startScheduler(t, db, job)
+
+			if err := s.Trigger(name); err != nil {
+				t.Fatalf("first trigger: %v", err)
+			}
+			if err := s.Trigger(name); !errors.Is(err, ErrAlreadyRunning) {
+				t.Errorf("trigger while running = %v, want ErrAlreadyRunning", err)
+			}
+
+			close(release)
+			<-done
+			waitIdle(t, s, name)
+
+			// Within the same second as the first run, which must not matter
+			if err := s.Trigger(name); err != nil {
+				t.Errorf("trigger after the run finished: %v", err)
+			}
+			<-done
+			waitIdle(t, s, name)
+		})
+	}
+}
+
+func TestTriggerRespectsLeasesOfOtherInstances(t *testing.T) {
+	db := database.New(filepath.Join(t.TempDir(), "bookings.db"))
+	t.Cleanup(func() { db.Close() })
+
+	job, release, done := blockingJob("shared", false)
+	first := startScheduler(t, db, job)
+	second := startScheduler(t, db, job)
+
+	if err := first.Trigger("shared"); err != nil {
+		t.Fatalf("trigger on the first instance: %v", err)
+	}
+	if err := second.Trigger("shared"); !errors.Is(err, ErrLeased) {
+		t.Errorf("trigger on the second instance = %v, want ErrLeased", err)
+	}
+
+	close(release)
+	<-done
+	waitIdle(t, first, "shared")
+	if err := second.Trigger("shared"); err != nil {
+		t.Errorf("trigger on the second instance once the lease is released: %v", err)
+	}
+	<-done
+}
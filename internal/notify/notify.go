@@ -0,0 +1,303 @@
+package notify
+
+import (
+	"booker-be/internal/database"
+	"booker-be/internal/events"
+	"booker-be/internal/protocol"
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Templates, each defining a "subject" and a "body"
+const (
+	TemplateBookingCreated   = "booking_created"
+	TemplateBookingChanged   = "booking_changed"
+	TemplateBookingCancelled = "booking_cancelled"
+	TemplateArrivalsDigest   = "arrivals_digest"
+)
+
+const queueSize = 256
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// Booking fields mentioned in change notifications, in the order they are listed
+var changeLabels = []struct{ field, label string }{
+	{"start_date", "Arrival"},
+	{"end_date", "Departure"},
+	{"guest_name", "Guest"},
+	{"adults", "Adults"},
+	{"children", "Children"},
+	{"notes", "Notes"},
+}
+
+// bookingData is passed to the booking templates
+type bookingData struct {
+	Recipient string
+	Actor     string
+	Group     string
+	Property  string
+	Booking   protocol.BookingResponse
+	Changes   []changeLine
+}
+
+type changeLine struct {
+	Field string
+	From  string
+	To    string
+}
+
+// digestData is passed to the arrivals digest template
+type digestData struct {
+	Recipient string
+	Date      string
+	Arrivals  []arrival
+}
+
+type arrival struct {
+	Group    string
+	Property string
+	Booking  database.Booking
+}
+
+// Notifier emails group members about booking changes and upcoming arrivals,
+// according to their notification preferences
+type Notifier struct {
+	db        database.Service
+	sender    Sender
+	templates map[string]*template.Template
+	queue     chan events.Event
+}
+
+func New(db database.Service, sender Sender) (*Notifier, error) {
+	templates := make(map[string]*template.Template)
+	for _, name := range []string{TemplateBookingCreated, TemplateBookingChanged, TemplateBookingCancelled, TemplateArrivalsDigest} {
+		t, err := template.ParseFS(templateFS, "templates/partials.tmpl", "templates/"+name+".tmpl")
+		if err != nil {
+			return nil, err
+		}
+		templates[name] = t
+	}
+
+	return &Notifier{
+		db:        db,
+		sender:    sender,
+		templates: templates,
+		queue:     make(chan events.Event, queueSize),
+	}, nil
+}
+
+// OnEvent queues booking events for notification. It is registered with
+// events.Hub.OnPublish and never blocks the publisher.
+func (n *Notifier) OnEvent(e events.Event) {
+	switch e.Type {
+	case events.BookingCreated, events.BookingUpdated, events.BookingDeleted:
+	default:
+		return
+	}
+
+	select {
+	case n.queue <- e:
+	default:
+		slog.Warn("Notification queue is full, dropping event", "event_type", e.Type, "event_id", e.ID)
+	}
+}
+
+// Run sends the notifications of queued events until the context is cancelled.
+// Events still queued at that point are sent before it returns.
+func (n *Notifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case e := <-n.queue:
+					n.notifyBookingEvent(e)
+				default:
+					return
+				}
+			}
+		case e := <-n.queue:
+			n.notifyBookingEvent(e)
+		}
+	}
+}
+
+// SendArrivalsDigests emails everyone who wants the digest the bookings arriving on
+// a date (YYYY-MM-DD), in one message covering all of their groups
+func (n *Notifier) SendArrivalsDigests(date string) error {
+	bookings, err := n.db.GetBookingsByStartDate(date)
+	if err != nil {
+		return err
+	}
+
+	groupNames := make(map[string]string)
+	byGroup := make(map[string][]arrival)
+	for _, b := range bookings {
+		property, err := n.db.GetPropertyByID(b.PropertyID)
+		if err != nil {
+			continue
+		}
+		if _, ok := groupNames[property.GroupID]; !ok {
+			group, err := n.db.GetGroupByID(property.GroupID)
+			if err != nil {
+				continue
+			}
+			groupNames[property.GroupID] = group.Name
+		}
+		byGroup[property.GroupID] = append(byGroup[property.GroupID], arrival{
+			Group:    groupNames[property.GroupID],
+			Property: property.Name,
+			Booking:  b,
+		})
+	}
+
+	byUser := make(map[string][]arrival)
+	for groupID, arrivals := range byGroup {
+		members, err := n.db.GetAllGroupUsersByGroupID(groupID)
+		if err != nil {
+			return err
+		}
+		for _, m := range members {
+			byUser[m.UserID] = append(byUser[m.UserID], arrivals...)
+		}
+	}
+
+	var errs []error
+	for userID, arrivals := range byUser {
+		user, ok := n.recipient(userID, func(p database.NotificationPreferences) bool { return p.ArrivalsDigest })
+		if !ok {
+			continue
+		}
+
+		sort.Slice(arrivals, func(i, j int) bool {
+			a, b := arrivals[i], arrivals[j]
+			if a.Group != b.Group {
+				return a.Group < b.Group
+			}
+			if a.Property != b.Property {
+				return a.Property < b.Property
+			}
+			return a.Booking.GuestName < b.Booking.GuestName
+		})
+		errs = append(errs, n.send(user.Email, TemplateArrivalsDigest, digestData{
+			Recipient: user.Username,
+			Date:      date,
+			Arrivals:  arrivals,
+		}))
+	}
+	return errors.Join(errs...)
+}
+
+func (n *Notifier) notifyBookingEvent(e events.Event) {
+	booking, ok := e.Data.(protocol.BookingResponse)
+	if !ok {
+		return
+	}
+
+	data := bookingData{Booking: booking, Actor: "Someone", Property: "a property"}
+	var name string
+	switch e.Type {
+	case events.BookingCreated:
+		name = TemplateBookingCreated
+	case events.BookingUpdated:
+		name = TemplateBookingChanged
+		data.Changes = describeChanges(e.Changes)
+		if len(data.Changes) == 0 {
+			// Nothing a recipient would notice changed
+			return
+		}
+	case events.BookingDeleted:
+		name = TemplateBookingCancelled
+	}
+
+	group, err := n.db.GetGroupByID(e.GroupID)
+	if err != nil {
+		slog.Error("Error retrieving group for notification", "group_id", e.GroupID, "event_id", e.ID, "error", err)
+		return
+	}
+	data.Group = group.Name
+	// The property may be gone already when the booking was removed with it
+	if property, err := n.db.GetPropertyByID(booking.PropertyID); err == nil {
+		data.Property = property.Name
+	}
+	if actor, err := n.db.GetUserByID(e.ActorID); err == nil {
+		data.Actor = actor.Username
+	}
+
+	members, err := n.db.GetAllGroupUsersByGroupID(e.GroupID)
+	if err != nil {
+		slog.Error("Error retrieving group members for notification", "group_id", e.GroupID, "event_id", e.ID, "error", err)
+		return
+	}
+	for _, m := range members {
+		user, ok := n.recipient(m.UserID, func(p database.NotificationPreferences) bool {
+			return p.BookingChanges && (m.UserID != e.ActorID || p.OwnChanges)
+		})
+		if !ok {
+			continue
+		}
+
+		data.Recipient = user.Username
+		if err := n.send(user.Email, name, data); err != nil {
+			slog.Error("Error sending notification", "user_id", user.ID, "event_id", e.ID, "error", err)
+		}
+	}
+}
+
+// recipient loads a user with an email address whose preferences accept a notification
+func (n *Notifier) recipient(userID string, wants func(database.NotificationPreferences) bool) (database.User, bool) {
+	user, err := n.db.GetUserByID(userID)
+	if err != nil || user.Email == "" {
+		return database.User{}, false
+	}
+	prefs, err := n.db.GetNotificationPreferences(userID)
+	if err != nil || !wants(prefs) {
+		return database.User{}, false
+	}
+	return user, true
+}
+
+func (n *Notifier) send(to, name string, data any) error {
+	var subject, body bytes.Buffer
+	t := n.templates[name]
+	if err := t.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return err
+	}
+	if err := t.ExecuteTemplate(&body, "body", data); err != nil {
+		return err
+	}
+
+	return n.sender.Send(Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Body:    body.String(),
+	})
+}
+
+func describeChanges(changes map[string]events.Change) []changeLine {
+	var lines []changeLine
+	for _, l := range changeLabels {
+		change, ok := changes[l.field]
+		if !ok {
+			continue
+		}
+		lines = append(lines, changeLine{Field: l.label, From: changeValue(change.From), To: changeValue(change.To)})
+	}
+	return lines
+}
+
+func changeValue(v any) string {
+	s := fmt.Sprint(v)
+	if v == nil || s == "" {
+		return "(empty)"
+	}
+	return s
+}
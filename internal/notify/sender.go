@@ -0,0 +1,108 @@
+package notify
+
+import (
+	"booker-be/internal/config"
+	"bytes"
+	"fmt"
+	"log/slog"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Message is a plain text email to a single recipient
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers email messages
+type Sender interface {
+	Send(msg Message) error
+}
+
+// SMTPSender sends messages through an SMTP server, using STARTTLS when the server offers it
+type SMTPSender struct {
+	addr     string
+	from     string
+	envelope string // Bare address of from, for the SMTP envelope
+	auth     smtp.Auth
+}
+
+// NewSMTPSender creates a sender for the server at addr (host:port).
+// Authentication is skipped when username is empty.
+func NewSMTPSender(addr, username, password, from string) *SMTPSender {
+	s := &SMTPSender{addr: addr, from: from, envelope: from}
+	if a, err := mail.ParseAddress(from); err == nil {
+		s.envelope = a.Address
+	}
+	if username != "" {
+		host, _, _ := strings.Cut(addr, ":")
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return s
+}
+
+func (s *SMTPSender) Send(msg Message) error {
+	return smtp.SendMail(s.addr, s.auth, s.envelope, []string{msg.To}, encode(s.from, msg))
+}
+
+// LogSender is a development sender. It writes every message as an .eml file
+// into Dir, or logs it when Dir is empty.
+type LogSender struct {
+	Dir  string
+	From string
+
+	count atomic.Uint64
+}
+
+func (s *LogSender) Send(msg Message) error {
+	if s.Dir == "" {
+		slog.Info("Email", "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+		return nil
+	}
+
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d-%d.eml", time.Now().UnixNano(), s.count.Add(1))
+	return os.WriteFile(filepath.Join(s.Dir, name), encode(s.From, msg), 0o644)
+}
+
+// NewSender sends through the configured SMTP server, or writes messages to the
+// outbox directory, or to the log, for development when there is none
+func NewSender(cfg config.Mail) Sender {
+	if cfg.SMTPAddr != "" {
+		return NewSMTPSender(cfg.SMTPAddr, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From)
+	}
+	return &LogSender{Dir: cfg.OutboxDir, From: cfg.From}
+}
+
+// encode renders a message in RFC 5322 format with a quoted-printable UTF-8 body
+func encode(from string, msg Message) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", headerValue(from))
+	fmt.Fprintf(&b, "To: %s\r\n", headerValue(msg.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", headerValue(msg.Subject)))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	w := quotedprintable.NewWriter(&b)
+	_, _ = w.Write([]byte(strings.ReplaceAll(msg.Body, "\n", "\r\n")))
+	_ = w.Close()
+	return b.Bytes()
+}
+
+// headerValue keeps a value on one line so it cannot inject further headers
+func headerValue(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -9,80 +9,125 @@ import (
 )
 
 // Protocol messages for booking service
-type BookingMessage struct {
-	ID         string `json:"id"`
-	CreatedBy  string `json:"created_by"`
-	PropertyID string `json:"property_id"`
-	StartDate  string `json:"start_date"`
-	EndDate    string `json:"end_date"`
-	GuestName  string `json:"guest_name"`
-	Adults     int    `json:"adults"`
-	Children   int    `json:"children"`
-}
-
 type CreateBookingMessage struct {
-	StartDate string `json:"start_date"`
-	EndDate   string `json:"end_date"`
-	GuestName string `json:"guest_name"`
-	Adults    int    `json:"adults"`
-	Children  int    `json:"children"`
+	StartDate string `json:"start_date" binding:"required,date"`
+	EndDate   string `json:"end_date" binding:"required,date,notbefore=StartDate"`
+	GuestName string `json:"guest_name" binding:"max=200"`
+	Adults    int    `json:"adults" binding:"min=0,max=50"`
+	Children  int    `json:"children" binding:"min=0,max=50"`
+	GuestID   string `json:"guest_id" binding:"omitempty,uuid"`
+	Notes     string `json:"notes"`
 }
 
 type UpdateBookingMessage struct {
-	StartDate string `json:"start_date"`
-	EndDate   string `json:"end_date"`
-	GuestName string `json:"guest_name"`
-	Adults    int    `json:"adults"`
-	Children  int    `json:"children"`
+	StartDate string `json:"start_date" binding:"required,date"`
+	EndDate   string `json:"end_date" binding:"required,date,notbefore=StartDate"`
+	GuestName string `json:"guest_name" binding:"max=200"`
+	Adults    int    `json:"adults" binding:"min=0,max=50"`
+	Children  int    `json:"children" binding:"min=0,max=50"`
+	GuestID   string `json:"guest_id" binding:"omitempty,uuid"`
+	Notes     string `json:"notes"`
 }
 
-// Protocol messages for user service
-type UserMessage struct {
-	ID       string `json:"id"`
-	Username string `json:"username"`
+type CreateCommentMessage struct {
+	ParentID string `json:"parent_id" binding:"omitempty,uuid"`
+	Body     string `json:"body" binding:"notblank,max=4000"`
+}
+
+type UpdateCommentMessage struct {
+	Body string `json:"body" binding:"notblank,max=4000"`
 }
 
+// Protocol messages for user service
 type CreateUserMessage struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username string `json:"username" binding:"notblank,max=50"`
+	Password string `json:"password" binding:"required,max=72"`
+	Email    string `json:"email" binding:"omitempty,max=254,email"`
+}
+
+type UpdateUserMessage struct {
+	Email string `json:"email" binding:"omitempty,max=254,email"` // Empty to stop receiving notification emails
+}
+
+type NotificationPreferencesMessage struct {
+	BookingChanges bool `json:"booking_changes"`
+	OwnChanges     bool `json:"own_changes"`
+	ArrivalsDigest bool `json:"arrivals_digest"`
 }
 
 type LoginUserMessage struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
 }
 
 // Protocol messages for group code service
 type GroupCodeMessage struct {
-	GroupID string `json:"group_id"`
+	GroupID string `json:"group_id" binding:"required,uuid"`
 }
 
 // Protocol messages for group service
 type GroupCreateMessage struct {
-	Name string `json:"name"`
+	Name string `json:"name" binding:"notblank,max=100"`
 }
 
 // Protocol messages for property service
 type CreatePropertyMessage struct {
-	GroupID string `json:"group_id"`
-	Name    string `json:"name"`
+	GroupID string `json:"group_id" binding:"required,uuid"`
+	Name    string `json:"name" binding:"notblank,max=100"`
 }
 
 type UpdatePropertyMessage struct {
-	Color string `json:"color"`
+	Color string `json:"color" binding:"omitempty,rgbhex"`
+}
+
+// Protocol messages for guest service
+type GuestMessage struct {
+	Name           string `json:"name" binding:"notblank,max=200"`
+	Email          string `json:"email" binding:"omitempty,email,max=254"`
+	Phone          string `json:"phone" binding:"max=50"`
+	Country        string `json:"country" binding:"omitempty,iso3166_1_alpha2"`
+	DocumentNumber string `json:"document_number" binding:"max=50"`
+	Notes          string `json:"notes"`
+}
+
+// Protocol messages for guest registration service
+type GuestRegistrationMessage struct {
+	FirstName      string `json:"first_name" binding:"notblank,max=100"`
+	LastName       string `json:"last_name" binding:"notblank,max=100"`
+	BirthDate      string `json:"birth_date" binding:"required,date,notfuture"`
+	Nationality    string `json:"nationality" binding:"required,iso3166_1_alpha2"`
+	DocumentType   string `json:"document_type" binding:"required,oneof=passport id_card driving_licence residence_permit other"` // Accepted by the authorities' portal
+	DocumentNumber string `json:"document_number" binding:"notblank,max=50"`
+}
+
+type GuestRegistrationStatusMessage struct {
+	IDs      []string `json:"ids" binding:"required,min=1,dive,uuid"`
+	Reported bool     `json:"reported"`
+}
+
+// Protocol messages for tourist tax service
+type TouristTaxRuleMessage struct {
+	PropertyID     string  `json:"property_id" binding:"omitempty,uuid"`
+	AdultRate      float64 `json:"adult_rate" binding:"min=0"`
+	ChildRate      float64 `json:"child_rate" binding:"min=0"`
+	ChildUnderAge  int     `json:"child_under_age" binding:"min=0,max=120"`
+	ExemptUnderAge int     `json:"exempt_under_age" binding:"min=0,max=120"`
+	Currency       string  `json:"currency" binding:"omitempty,len=3"`
+	ValidFrom      string  `json:"valid_from" binding:"omitempty,date"`
+	ValidTo        string  `json:"valid_to" binding:"omitempty,date,notbefore=ValidFrom"`
 }
 
-type GroupMessage struct {
-	ID        string `json:"id"`
-	CreatedAt string `json:"created_at"`
-	Name      string `json:"name"`
-	OwnerID   string `json:"owner_id"`
+// Protocol messages for webhook service
+type WebhookMessage struct {
+	URL        string   `json:"url" binding:"required,url"`
+	EventTypes []string `json:"event_types" binding:"required,min=1"`
+	Secret     string   `json:"secret" binding:"omitempty,min=16"` // Generated when empty on create, kept when empty on update
+	Active     *bool    `json:"active"`                            // Defaults to true
 }
 
-// HashPassword generates a bcrypt hash of the password
-// Cost factor of 14 provides good security while maintaining reasonable performance
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+// HashPassword generates a bcrypt hash of the password with the given cost factor
+func HashPassword(password string, cost int) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), cost)
 	if err != nil {
 		return "", err
 	}
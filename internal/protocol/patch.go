@@ -0,0 +1,61 @@
+package protocol
+
+import (
+	"encoding/json"
+)
+
+// Optional is a field of a JSON merge patch (RFC 7396): when the field is absent the
+// value is left unchanged, null clears it and any other value replaces it
+type Optional[T any] struct {
+	Set   bool // The field is in the patch
+	Null  bool // The field is null
+	Value T
+}
+
+// UnmarshalJSON is only called for fields that are in the patch, null included
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Null = true
+		return nil
+	}
+	return json.Unmarshal(data, &o.Value)
+}
+
+// Apply patches dst
+func (o Optional[T]) Apply(dst *T) {
+	if !o.Set {
+		return
+	}
+	var zero T
+	if o.Null {
+		*dst = zero
+		return
+	}
+	*dst = o.Value
+}
+
+// WrappedValue returns a value of the wrapped type, to document the field
+func (o Optional[T]) WrappedValue() any {
+	var zero T
+	return zero
+}
+
+// validationValue is what binding rules check: the new value, or nil when unchanged or cleared
+func (o Optional[T]) validationValue() any {
+	if !o.Set || o.Null {
+		return nil
+	}
+	return o.Value
+}
+
+// PatchBookingMessage changes some fields of a booking. The dates can't be cleared.
+type PatchBookingMessage struct {
+	StartDate Optional[string] `json:"start_date" binding:"omitempty,date"`
+	EndDate   Optional[string] `json:"end_date" binding:"omitempty,date"`
+	GuestName Optional[string] `json:"guest_name" binding:"omitempty,max=200"`
+	Adults    Optional[int]    `json:"adults" binding:"omitempty,min=0,max=50"`
+	Children  Optional[int]    `json:"children" binding:"omitempty,min=0,max=50"`
+	GuestID   Optional[string] `json:"guest_id" binding:"omitempty,uuid"`
+	Notes     Optional[string] `json:"notes"`
+}
@@ -0,0 +1,185 @@
+package protocol
+
+// Response messages are what the API returns. They are kept apart from the database
+// models, so that a stored column such as a password hash, a webhook secret or a
+// storage key only reaches clients when it is copied here on purpose.
+
+type UserResponse struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+type NotificationPreferencesResponse struct {
+	UserID         string `json:"user_id"`
+	UpdatedAt      string `json:"updated_at"`
+	BookingChanges bool   `json:"booking_changes"`
+	OwnChanges     bool   `json:"own_changes"`
+	ArrivalsDigest bool   `json:"arrivals_digest"`
+}
+
+type GroupResponse struct {
+	ID        string `json:"id"`
+	CreatedAt string `json:"created_at"`
+	Name      string `json:"name"`
+	OwnerID   string `json:"owner_id"`
+	Version   int    `json:"version"`
+}
+
+type GroupCodeResponse struct {
+	GroupID  string `json:"group_id"`
+	Code     string `json:"code"`
+	ActiveTo string `json:"active_to"`
+}
+
+type PropertyResponse struct {
+	ID        string `json:"id"`
+	CreatedAt string `json:"created_at"`
+	GroupID   string `json:"group_id"`
+	Name      string `json:"name"`
+	Color     string `json:"color"`
+	Version   int    `json:"version"` // Also sent as the ETag
+}
+
+type BookingResponse struct {
+	ID         string `json:"id"`
+	CreatedAt  string `json:"created_at"`
+	CreatedBy  string `json:"created_by"`
+	PropertyID string `json:"property_id"`
+	StartDate  string `json:"start_date"`
+	EndDate    string `json:"end_date"`
+	GuestName  string `json:"guest_name"`
+	Adults     int    `json:"adults"`
+	Children   int    `json:"children"`
+	GuestID    string `json:"guest_id"`
+	Notes      string `json:"notes"`
+	Version    int    `json:"version"` // Also sent as the ETag
+}
+
+type CommentResponse struct {
+	ID         string `json:"id"`
+	CreatedAt  string `json:"created_at"`
+	UpdatedAt  string `json:"updated_at"`
+	BookingID  string `json:"booking_id"`
+	ParentID   string `json:"parent_id"`
+	AuthorID   string `json:"author_id"`
+	AuthorName string `json:"author_name"`
+	Body       string `json:"body"`
+	Deleted    bool   `json:"deleted"`
+}
+
+// CommentThreadResponse is a comment with its replies nested below it
+type CommentThreadResponse struct {
+	CommentResponse
+	Replies []*CommentThreadResponse `json:"replies"`
+}
+
+type GuestResponse struct {
+	ID             string `json:"id"`
+	CreatedAt      string `json:"created_at"`
+	GroupID        string `json:"group_id"`
+	Name           string `json:"name"`
+	Email          string `json:"email"`
+	Phone          string `json:"phone"`
+	Country        string `json:"country"`
+	DocumentNumber string `json:"document_number"`
+	Notes          string `json:"notes"`
+}
+
+// GuestDetailResponse is a guest with their stay history
+type GuestDetailResponse struct {
+	Guest  GuestResponse     `json:"guest"`
+	Stays  []BookingResponse `json:"stays"`
+	Totals GuestStayTotals   `json:"totals"`
+}
+
+type GuestStayTotals struct {
+	Stays         int    `json:"stays"`
+	Nights        int    `json:"nights"`
+	UpcomingStays int    `json:"upcoming_stays"`
+	FirstStay     string `json:"first_stay"` // Start date of the first past stay
+	LastStay      string `json:"last_stay"`  // Start date of the last past stay
+}
+
+type GuestRegistrationResponse struct {
+	ID             string `json:"id"`
+	CreatedAt      string `json:"created_at"`
+	BookingID      string `json:"booking_id"`
+	FirstName      string `json:"first_name"`
+	LastName       string `json:"last_name"`
+	BirthDate      string `json:"birth_date"`
+	Nationality    string `json:"nationality"`
+	DocumentType   string `json:"document_type"`
+	DocumentNumber string `json:"document_number"`
+	Reported       bool   `json:"reported"`
+	ReportedAt     string `json:"reported_at"`
+}
+
+// SearchResponse holds the matches of a group search by entity type, best first
+type SearchResponse struct {
+	Bookings   []BookingResponse  `json:"bookings"`
+	Guests     []GuestResponse    `json:"guests"`
+	Properties []PropertyResponse `json:"properties"`
+}
+
+type TouristTaxRuleResponse struct {
+	ID             string  `json:"id"`
+	CreatedAt      string  `json:"created_at"`
+	GroupID        string  `json:"group_id"`
+	PropertyID     string  `json:"property_id"`
+	AdultRate      float64 `json:"adult_rate"`
+	ChildRate      float64 `json:"child_rate"`
+	ChildUnderAge  int     `json:"child_under_age"`
+	ExemptUnderAge int     `json:"exempt_under_age"`
+	Currency       string  `json:"currency"`
+	ValidFrom      string  `json:"valid_from"`
+	ValidTo        string  `json:"valid_to"`
+}
+
+type ActivityResponse struct {
+	ID         string `json:"id"`
+	CreatedAt  string `json:"created_at"`
+	GroupID    string `json:"group_id"`
+	UserID     string `json:"user_id"`
+	Action     string `json:"action"`
+	EntityType string `json:"entity_type"`
+	EntityID   string `json:"entity_id"`
+	Summary    string `json:"summary"`
+}
+
+type AttachmentResponse struct {
+	ID          string `json:"id"`
+	CreatedAt   string `json:"created_at"`
+	CreatedBy   string `json:"created_by"`
+	GroupID     string `json:"group_id"`
+	ParentType  string `json:"parent_type"`
+	ParentID    string `json:"parent_id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+type WebhookResponse struct {
+	ID         string   `json:"id"`
+	CreatedAt  string   `json:"created_at"`
+	CreatedBy  string   `json:"created_by"`
+	GroupID    string   `json:"group_id"`
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Active     bool     `json:"active"`
+	Secret     string   `json:"secret,omitempty"` // Only when the webhook is created
+}
+
+type WebhookDeliveryResponse struct {
+	ID             string `json:"id"`
+	CreatedAt      string `json:"created_at"`
+	WebhookID      string `json:"webhook_id"`
+	EventType      string `json:"event_type"`
+	Payload        string `json:"payload"`
+	Status         string `json:"status"`
+	Attempts       int    `json:"attempts"`
+	NextAttemptAt  int64  `json:"next_attempt_at"`
+	ResponseStatus int    `json:"response_status"`
+	LastError      string `json:"last_error"`
+	DeliveredAt    string `json:"delivered_at"`
+}
@@ -0,0 +1,71 @@
+package protocol
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Validation rules are declared on the messages with binding tags. Besides the
+// validator's built-in rules, messages use:
+//
+//	date          a YYYY-MM-DD date
+//	notbefore=F   a date that is not before the date in field F, when both are set
+//	notblank      a string that isn't empty once trimmed
+//	notfuture     a date that is not after today
+//	rgbhex        a #RRGGBB color
+var rgbHexPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// RegisterValidators adds the custom rules to v and makes it report fields by their JSON names
+func RegisterValidators(v *validator.Validate) error {
+	v.RegisterTagNameFunc(JSONFieldName)
+
+	rules := map[string]validator.Func{
+		"date": func(fl validator.FieldLevel) bool {
+			return IsValidDate(fl.Field().String())
+		},
+		"notbefore": func(fl validator.FieldLevel) bool {
+			other, _, ok := fl.GetStructFieldOK()
+			if !ok || other.Kind() != reflect.String || other.String() == "" || fl.Field().String() == "" {
+				return true
+			}
+			// YYYY-MM-DD dates sort like strings
+			return fl.Field().String() >= other.String()
+		},
+		"notfuture": func(fl validator.FieldLevel) bool {
+			return fl.Field().String() <= time.Now().Format("2006-01-02")
+		},
+		"notblank": func(fl validator.FieldLevel) bool {
+			return strings.TrimSpace(fl.Field().String()) != ""
+		},
+		"rgbhex": func(fl validator.FieldLevel) bool {
+			return rgbHexPattern.MatchString(fl.Field().String())
+		},
+	}
+	for tag, fn := range rules {
+		if err := v.RegisterValidation(tag, fn); err != nil {
+			return err
+		}
+	}
+
+	// Rules on optional fields apply to the new value, when there is one
+	v.RegisterCustomTypeFunc(func(field reflect.Value) any {
+		return field.Interface().(interface{ validationValue() any }).validationValue()
+	}, Optional[string]{}, Optional[int]{})
+	return nil
+}
+
+// JSONFieldName returns the name of a struct field in JSON, or "" when it isn't encoded
+func JSONFieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return f.Name
+	}
+	return name
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"booker-be/internal/database"
+	"booker-be/internal/protocol"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultActivityLimit = 50
+	maxActivityLimit     = 200
+)
+
+// GetActivityByGroupID returns a group's recent activity, newest first (?limit=)
+func GetActivityByGroupID(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		groupID := c.Param("groupID")
+
+		// Check if user belongs to this group
+		if !db.UserBelongsToGroup(userID.(string), groupID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this group")
+			return
+		}
+
+		limit := defaultActivityLimit
+		if l := c.Query("limit"); l != "" {
+			n, err := strconv.Atoi(l)
+			if err != nil || n < 1 {
+				abortWithError(c, invalidField("limit", fieldOutOfRange, "Invalid limit"))
+				return
+			}
+			limit = min(n, maxActivityLimit)
+		}
+
+		activity, err := db.GetActivityByGroupID(groupID, limit)
+		if err != nil {
+			respondError(c, CodeInternal, "Failed to retrieve activity")
+			return
+		}
+		c.JSON(200, mapAll(activity, newActivityResponse))
+	}
+}
+
+// recordActivity adds an entry to a group's activity history.
+// The history is informational, so a failure to record does not fail the request.
+func recordActivity(db database.Service, groupID, userID, action, entityType, entityID, summary string) {
+	_ = db.InsertActivity(database.Activity{
+		ID:         protocol.GenerateID(),
+		CreatedAt:  protocol.GetCurrentTime(),
+		GroupID:    groupID,
+		UserID:     userID,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Summary:    summary,
+	})
+}
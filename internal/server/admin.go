@@ -0,0 +1,39 @@
+package server
+
+import (
+	"booker-be/internal/jobs"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJobs returns the schedule and last run of every background job
+func GetJobs(scheduler *jobs.Scheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		statuses, err := scheduler.Statuses()
+		if err != nil {
+			respondError(c, CodeInternal, "Failed to retrieve jobs")
+			return
+		}
+		c.JSON(200, statuses)
+	}
+}
+
+// RunJob starts a background job right away
+func RunJob(scheduler *jobs.Scheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := scheduler.Trigger(c.Param("name"))
+		switch {
+		case errors.Is(err, jobs.ErrUnknownJob):
+			respondError(c, CodeNotFound, "Job not found")
+		case errors.Is(err, jobs.ErrAlreadyRunning):
+			respondError(c, CodeConflict, "Job is already running")
+		case errors.Is(err, jobs.ErrLeased):
+			respondError(c, CodeConflict, "Job is running on another instance")
+		case err != nil:
+			respondError(c, CodeInternal, "Failed to start job")
+		default:
+			c.JSON(202, gin.H{"message": "Job started successfully"})
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package server
+
+import (
+	"booker-be/internal/database"
+	"booker-be/internal/protocol"
+	"booker-be/internal/storage"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	attachmentParentBooking  = "booking"
+	attachmentParentProperty = "property"
+
+	maxAttachmentSize = 10 << 20 // 10 MB
+)
+
+// Content types accepted for attachments, as sniffed from the uploaded bytes
+var allowedAttachmentTypes = map[string]bool{
+	"application/pdf": true,
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+}
+
+func GetBookingAttachments(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		bookingID := c.Param("bookingID")
+
+		// Check if user can access this booking
+		if !db.UserCanAccessBooking(userID.(string), bookingID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this booking")
+			return
+		}
+
+		listAttachments(c, db, attachmentParentBooking, bookingID)
+	}
+}
+
+func GetPropertyAttachments(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		propertyID := c.Param("propertyID")
+
+		// Check if user belongs to the group that owns this property
+		if !db.UserBelongsToPropertyGroup(userID.(string), propertyID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this property")
+			return
+		}
+
+		listAttachments(c, db, attachmentParentProperty, propertyID)
+	}
+}
+
+// UploadBookingAttachment stores the multipart "file" field as an attachment of a booking
+func UploadBookingAttachment(db database.Service, blobs storage.BlobStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		bookingID := c.Param("bookingID")
+
+		// Check if user can access this booking
+		if !db.UserCanAccessBooking(userID.(string), bookingID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this booking")
+			return
+		}
+
+		groupID, ok := bookingGroupID(db, bookingID)
+		if !ok {
+			respondError(c, CodeNotFound, "Booking not found")
+			return
+		}
+
+		uploadAttachment(c, db, blobs, userID.(string), groupID, attachmentParentBooking, bookingID)
+	}
+}
+
+// UploadPropertyAttachment stores the multipart "file" field as an attachment of a property
+func UploadPropertyAttachment(db database.Service, blobs storage.BlobStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		propertyID := c.Param("propertyID")
+
+		// Check if user belongs to the group that owns this property
+		if !db.UserBelongsToPropertyGroup(userID.(string), propertyID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this property")
+			return
+		}
+
+		property, err := db.GetPropertyByID(propertyID)
+		if err != nil {
+			respondError(c, CodeNotFound, "Property not found")
+			return
+		}
+
+		uploadAttachment(c, db, blobs, userID.(string), property.GroupID, attachmentParentProperty, propertyID)
+	}
+}
+
+func DownloadAttachment(db database.Service, blobs storage.BlobStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		attachment, ok := accessibleAttachment(c, db, userID.(string))
+		if !ok {
+			return
+		}
+
+		blob, err := blobs.Open(attachment.StorageKey)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				respondError(c, CodeNotFound, "Attachment content not found")
+				return
+			}
+			respondError(c, CodeInternal, "Failed to read attachment")
+			return
+		}
+		defer blob.Close()
+
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.DataFromReader(200, attachment.Size, attachment.ContentType, blob, map[string]string{
+			"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}),
+		})
+	}
+}
+
+func DeleteAttachment(db database.Service, blobs storage.BlobStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		attachment, ok := accessibleAttachment(c, db, userID.(string))
+		if !ok {
+			return
+		}
+
+		if err := db.DeleteAttachment(attachment.ID); err != nil {
+			respondError(c, CodeInternal, "Failed to delete attachment")
+			return
+		}
+		// The record is gone, so a blob left behind is unreachable and harmless
+		_ = blobs.Delete(attachment.StorageKey)
+
+		c.JSON(200, gin.H{"message": "Attachment deleted successfully"})
+	}
+}
+
+func listAttachments(c *gin.Context, db database.Service, parentType, parentID string) {
+	attachments, err := db.GetAttachmentsByParent(parentType, parentID)
+	if err != nil {
+		respondError(c, CodeInternal, "Failed to retrieve attachments")
+		return
+	}
+	c.JSON(200, mapAll(attachments, newAttachmentResponse))
+}
+
+func uploadAttachment(c *gin.Context, db database.Service, blobs storage.BlobStore, userID, groupID, parentType, parentID string) {
+	// Leave some room for the multipart envelope around the file itself
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxAttachmentSize+1<<20)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondError(c, CodePayloadTooLarge, "Attachment exceeds the maximum size of "+strconv.Itoa(maxAttachmentSize>>20)+" MB")
+			return
+		}
+		respondError(c, CodeInvalidInput, "A file is required in the \"file\" form field")
+		return
+	}
+	if header.Size > maxAttachmentSize {
+		respondError(c, CodePayloadTooLarge, "Attachment exceeds the maximum size of "+strconv.Itoa(maxAttachmentSize>>20)+" MB")
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		respondError(c, CodeInvalidInput, "Failed to read uploaded file")
+		return
+	}
+	defer file.Close()
+
+	// Trust the content, not the client supplied Content-Type
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(file, sniff)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		respondError(c, CodeInvalidInput, "Failed to read uploaded file")
+		return
+	}
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(sniff[:n]))
+	if !allowedAttachmentTypes[contentType] {
+		respondError(c, CodeUnsupportedMediaType, "Unsupported file type. Allowed: PDF, JPEG, PNG, GIF, WebP")
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		respondError(c, CodeInternal, "Failed to read uploaded file")
+		return
+	}
+
+	a := database.Attachment{
+		ID:          protocol.GenerateID(),
+		CreatedAt:   protocol.GetCurrentTime(),
+		CreatedBy:   userID,
+		GroupID:     groupID,
+		ParentType:  parentType,
+		ParentID:    parentID,
+		Filename:    sanitizeFilename(header.Filename),
+		ContentType: contentType,
+		StorageKey:  protocol.GenerateID(),
+	}
+
+	a.Size, err = blobs.Put(a.StorageKey, file)
+	if err != nil {
+		respondError(c, CodeInternal, "Failed to store attachment")
+		return
+	}
+
+	if err := db.InsertAttachment(a); err != nil {
+		_ = blobs.Delete(a.StorageKey)
+		respondError(c, CodeInternal, "Failed to create attachment")
+		return
+	}
+
+	respondCreated(c, "/attachments/"+a.ID, newAttachmentResponse(a))
+}
+
+// accessibleAttachment loads the attachment named in the URL and checks that the user
+// belongs to the group owning its property. On failure it writes the response and returns false.
+func accessibleAttachment(c *gin.Context, db database.Service, userID string) (database.Attachment, bool) {
+	attachment, err := db.GetAttachmentByID(c.Param("attachmentID"))
+	if err != nil {
+		respondError(c, CodeNotFound, "Attachment not found")
+		return database.Attachment{}, false
+	}
+
+	propertyID := attachment.ParentID
+	if attachment.ParentType == attachmentParentBooking {
+		booking, err := db.GetBookingByID(attachment.ParentID)
+		if err != nil {
+			respondError(c, CodeNotFound, "Attachment not found")
+			return database.Attachment{}, false
+		}
+		propertyID = booking.PropertyID
+	}
+
+	if !db.UserBelongsToPropertyGroup(userID, propertyID) {
+		respondError(c, CodeForbidden, "Forbidden: You don't have access to this attachment")
+		return database.Attachment{}, false
+	}
+
+	return attachment, true
+}
+
+// removeBlobs deletes the files of attachments whose records are gone. As in
+// DeleteAttachment, a file left behind is unreachable and harmless.
+func removeBlobs(blobs storage.BlobStore, attachments []database.Attachment) {
+	for _, a := range attachments {
+		_ = blobs.Delete(a.StorageKey)
+	}
+}
+
+// sanitizeFilename keeps only the base name of an uploaded file and strips control characters
+func sanitizeFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	return name
+}
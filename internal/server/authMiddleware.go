@@ -1,8 +1,8 @@
 package server // Or server.middleware
 
 import (
+	"booker-be/internal/logging"
 	"booker-be/internal/session" // Adjust import path if needed
-	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -20,25 +20,59 @@ func AuthMiddleware(sessionValidator session.SessionValidator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader(authorizationHeaderKey)
 		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is missing"})
+			respondError(c, CodeUnauthorized, "Authorization header is missing")
 			return
 		}
 
 		fields := strings.Fields(authHeader)
 		if len(fields) < 2 || !strings.EqualFold(fields[0], authorizationTypeBearer) {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format. Expected 'Bearer <token>'"})
+			respondError(c, CodeUnauthorized, "Invalid authorization header format. Expected 'Bearer <token>'")
 			return
 		}
 
 		accessToken := fields[1]
 		userID, err := sessionValidator.ValidateToken(accessToken)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired access token: " + err.Error()})
+			c.Error(err) // Logged with the request, the reason stays out of the response
+			respondError(c, CodeUnauthorized, "Invalid or expired access token")
 			return
 		}
 
 		// Set the userID in the context for subsequent handlers to use
 		c.Set(authorizationPayloadKey, userID)
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), "user_id", userID))
+		c.Next()
+	}
+}
+
+// StreamAuthMiddleware authenticates streaming endpoints. Browsers cannot set headers
+// on an EventSource or a WebSocket, so the token may also be passed as the access_token query parameter.
+func StreamAuthMiddleware(sessionValidator session.SessionValidator) gin.HandlerFunc {
+	authMW := AuthMiddleware(sessionValidator)
+	return func(c *gin.Context) {
+		if c.GetHeader(authorizationHeaderKey) == "" {
+			if token := c.Query("access_token"); token != "" {
+				c.Request.Header.Set(authorizationHeaderKey, authorizationTypeBearer+" "+token)
+			}
+		}
+		authMW(c)
+	}
+}
+
+// AdminMiddleware only lets the given users through. It runs after AuthMiddleware.
+func AdminMiddleware(adminUserIDs []string) gin.HandlerFunc {
+	admins := make(map[string]bool, len(adminUserIDs))
+	for _, id := range adminUserIDs {
+		if id = strings.TrimSpace(id); id != "" {
+			admins[id] = true
+		}
+	}
+	return func(c *gin.Context) {
+		userID, _ := c.Get(authorizationPayloadKey)
+		if id, ok := userID.(string); !ok || !admins[id] {
+			respondError(c, CodeForbidden, "Forbidden: Administrators only")
+			return
+		}
 		c.Next()
 	}
 }
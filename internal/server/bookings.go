@@ -2,16 +2,28 @@ package server
 
 import (
 	"booker-be/internal/database"
+	"booker-be/internal/events"
 	"booker-be/internal/protocol"
+	"booker-be/internal/storage"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	defaultBookingLimit = 100
+	maxBookingLimit     = 500
+)
+
+// GetBookingsByPropertyID lists the bookings of a property a page at a time, see listBookings
 func GetBookingsByPropertyID(db database.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		if !exists {
-			c.JSON(401, gin.H{"error": "Unauthorized"})
+			respondError(c, CodeUnauthorized, "Unauthorized")
 			return
 		}
 
@@ -19,24 +31,20 @@ func GetBookingsByPropertyID(db database.Service) gin.HandlerFunc {
 
 		// Check if user belongs to the group that owns this property
 		if !db.UserBelongsToPropertyGroup(userID.(string), propertyID) {
-			c.JSON(403, gin.H{"error": "Forbidden: You don't have access to this property"})
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this property")
 			return
 		}
 
-		bookings, err := db.GetBookingsByPropertyID(propertyID)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to retrieve bookings"})
-			return
-		}
-		c.JSON(200, bookings)
+		listBookings(c, db, database.BookingFilter{PropertyID: propertyID})
 	}
 }
 
+// GetBookingsByGroupID lists the bookings of every property of a group a page at a time, see listBookings
 func GetBookingsByGroupID(db database.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		if !exists {
-			c.JSON(401, gin.H{"error": "Unauthorized"})
+			respondError(c, CodeUnauthorized, "Unauthorized")
 			return
 		}
 
@@ -44,131 +52,214 @@ func GetBookingsByGroupID(db database.Service) gin.HandlerFunc {
 
 		// Check if user belongs to the group
 		if !db.UserBelongsToGroup(userID.(string), groupID) {
-			c.JSON(403, gin.H{"error": "Forbidden: You don't have access to this group"})
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this group")
 			return
 		}
 
-		// Get all properties for the group
-		properties, err := db.GetPropertiesByGroupID(groupID)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to retrieve properties"})
-			return
-		}
+		listBookings(c, db, database.BookingFilter{GroupID: groupID})
+	}
+}
+
+// listBookings responds with a page of the bookings of the group or property set on
+// filter, narrowed down by the query parameters:
+//
+//	from, to     bookings with a night in [from, to), YYYY-MM-DD
+//	property_id  bookings of one property
+//	guest_name   part of the guest name
+//	status       upcoming, current or past, as of today
+//	sort         start_date (default) or created_at, descending with a leading "-"
+//	limit        page size
+//	cursor       the X-Next-Cursor header of the previous page
+//
+// Without limit and cursor every booking is returned, as before listings had pages,
+// so that clients unaware of X-Next-Cursor never miss bookings.
+func listBookings(c *gin.Context, db database.Service, filter database.BookingFilter) {
+	sort, apiErr := parseBookingFilter(c, &filter)
+	if apiErr != nil {
+		abortWithError(c, apiErr)
+		return
+	}
 
-		// Extract property IDs
-		propertyIDs := make([]string, len(properties))
-		for i, p := range properties {
-			propertyIDs[i] = p.ID
+	// One booking more than the page tells whether there is a next page
+	limit := filter.Limit
+	if limit > 0 {
+		filter.Limit++
+	}
+	bookings, err := db.ListBookings(filter)
+	if err != nil {
+		respondError(c, CodeInternal, "Failed to retrieve bookings")
+		return
+	}
+
+	if limit > 0 && len(bookings) > limit {
+		bookings = bookings[:limit]
+		last := filter.CursorFor(bookings[limit-1])
+		c.Header(nextCursorHeader, encodeCursor(pageCursor{Sort: sort, Key: last.Key, ID: last.ID}))
+	}
+	c.JSON(200, mapAll(bookings, newBookingResponse))
+}
+
+// parseBookingFilter adds the query parameters of listBookings to filter.
+// It returns the sort parameter, which cursors are tied to.
+func parseBookingFilter(c *gin.Context, filter *database.BookingFilter) (string, *APIError) {
+	filter.From, filter.To = c.Query("from"), c.Query("to")
+	if filter.From != "" && !protocol.IsValidDate(filter.From) {
+		return "", invalidField("from", fieldInvalidFormat, "from must be a date formatted as YYYY-MM-DD")
+	}
+	if filter.To != "" && !protocol.IsValidDate(filter.To) {
+		return "", invalidField("to", fieldInvalidFormat, "to must be a date formatted as YYYY-MM-DD")
+	}
+	if filter.From != "" && filter.To != "" && filter.To < filter.From {
+		return "", invalidField("to", fieldOutOfRange, "to must not be before from")
+	}
+
+	if propertyID := c.Query("property_id"); propertyID != "" {
+		if filter.PropertyID != "" && propertyID != filter.PropertyID {
+			return "", invalidField("property_id", fieldInvalidValue, "property_id doesn't match the property in the path")
 		}
+		filter.PropertyID = propertyID
+	}
+	filter.GuestName = strings.TrimSpace(c.Query("guest_name"))
 
-		// Get bookings for all properties
-		if len(propertyIDs) == 0 {
-			c.JSON(200, []database.Booking{})
-			return
+	filter.Status = c.Query("status")
+	switch filter.Status {
+	case "", database.BookingStatusUpcoming, database.BookingStatusCurrent, database.BookingStatusPast:
+	default:
+		return "", invalidField("status", fieldInvalidValue, "status must be upcoming, current or past")
+	}
+	filter.Today = time.Now().Format("2006-01-02")
+
+	sort := c.DefaultQuery("sort", database.BookingSortStartDate)
+	filter.Sort = strings.TrimPrefix(sort, "-")
+	filter.Descending = filter.Sort != sort
+	if filter.Sort != database.BookingSortStartDate && filter.Sort != database.BookingSortCreatedAt {
+		return "", invalidField("sort", fieldInvalidValue, "sort must be start_date or created_at, with a leading - for descending order")
+	}
+
+	// Paging starts with the first limit or cursor, see listBookings
+	if c.Query("cursor") != "" {
+		filter.Limit = defaultBookingLimit
+	}
+	if l := c.Query("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 {
+			return "", invalidField("limit", fieldOutOfRange, "Invalid limit")
 		}
+		filter.Limit = min(n, maxBookingLimit)
+	}
 
-		bookings, err := db.GetBookingsByPropertyIds(propertyIDs)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to retrieve bookings"})
-			return
+	if s := c.Query("cursor"); s != "" {
+		cursor, ok := decodeCursor(s, sort)
+		if !ok {
+			return "", invalidField("cursor", fieldInvalidValue, "Invalid cursor, or a cursor of a listing in another order")
 		}
-		c.JSON(200, bookings)
+		filter.After = &database.BookingCursor{Key: cursor.Key, ID: cursor.ID}
 	}
+	return sort, nil
 }
 
-func CreateBooking(db database.Service) gin.HandlerFunc {
+// GetBooking returns a booking with its version as ETag, for a later If-Match
+func GetBooking(db database.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		if !exists {
-			c.JSON(401, gin.H{"error": "Unauthorized"})
+			respondError(c, CodeUnauthorized, "Unauthorized")
 			return
 		}
 
-		propertyID := c.Param("propertyID")
-		var booking protocol.CreateBookingMessage
-		if err := c.ShouldBindJSON(&booking); err != nil {
-			c.JSON(400, gin.H{"error": "Invalid input"})
-			return
-		}
+		bookingID := c.Param("bookingID")
 
-		if propertyID == "" {
-			c.JSON(400, gin.H{"error": "Property ID is required"})
+		// Check if user can access this booking
+		if !db.UserCanAccessBooking(userID.(string), bookingID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this booking")
 			return
 		}
 
-		// Check if the property exists
-		_, err := db.GetPropertyByID(propertyID)
+		booking, err := db.GetBookingByID(bookingID)
 		if err != nil {
-			c.JSON(404, gin.H{"error": "Property not found"})
+			respondError(c, CodeNotFound, "Booking not found")
 			return
 		}
 
-		// Check if user belongs to the group that owns this property
-		if !db.UserBelongsToPropertyGroup(userID.(string), propertyID) {
-			c.JSON(403, gin.H{"error": "Forbidden: You don't have access to this property"})
-			return
-		}
+		respondWithVersion(c, booking.Version, newBookingResponse(booking))
+	}
+}
 
-		// Check if the booking dates are valid (they are strings)
-		if booking.StartDate == "" || booking.EndDate == "" {
-			c.JSON(400, gin.H{"error": "Start date and end date are required"})
+func CreateBooking(db database.Service, hub *events.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
 			return
 		}
 
-		// Check if the booking dates are in the correct format
-		if !protocol.IsValidDate(booking.StartDate) || !protocol.IsValidDate(booking.EndDate) {
-			c.JSON(400, gin.H{"error": "Invalid date format"})
+		propertyID := c.Param("propertyID")
+		var booking protocol.CreateBookingMessage
+		if !bindJSON(c, &booking) {
 			return
 		}
 
-		// Check if end date is after start date
-		eD, err := protocol.ParseDate(booking.EndDate)
-		if err != nil {
-			c.JSON(400, gin.H{"error": "Invalid end date format"})
+		if propertyID == "" {
+			respondError(c, CodeInvalidInput, "Property ID is required")
 			return
 		}
 
-		sD, err := protocol.ParseDate(booking.StartDate)
+		// Check if the property exists
+		property, err := db.GetPropertyByID(propertyID)
 		if err != nil {
-			c.JSON(400, gin.H{"error": "Invalid start date format"})
+			respondError(c, CodeNotFound, "Property not found")
 			return
 		}
 
-		if eD.Before(sD) {
-			c.JSON(400, gin.H{"error": "End date must be after start date"})
+		// Check if user belongs to the group that owns this property
+		if !db.UserBelongsToPropertyGroup(userID.(string), propertyID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this property")
 			return
 		}
 
 		b := database.Booking{
 			ID:         protocol.GenerateID(),
+			CreatedAt:  protocol.GetCurrentTime(),
+			CreatedBy:  userID.(string),
 			PropertyID: propertyID,
 			StartDate:  booking.StartDate,
 			EndDate:    booking.EndDate,
 			GuestName:  booking.GuestName,
 			Adults:     booking.Adults,
 			Children:   booking.Children,
+			GuestID:    booking.GuestID,
+			Notes:      booking.Notes,
+			Version:    1,
+		}
+
+		if err := linkBookingGuest(db, propertyID, &b); err != nil {
+			abortWithError(c, err)
+			return
 		}
 
 		err = db.InsertBooking(b)
 		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to create booking"})
+			respondError(c, CodeInternal, "Failed to create booking")
 			return
 		}
-		c.JSON(201, gin.H{"message": "Booking created successfully"})
+
+		recordActivity(db, property.GroupID, userID.(string), "booking.created", "booking", b.ID, bookingSummary(b))
+		publish(hub, property.GroupID, userID.(string), events.BookingCreated, newBookingResponse(b))
+		c.Header("ETag", etag(b.Version))
+		respondCreated(c, "/bookings/"+b.ID, newBookingResponse(b))
 	}
 }
 
-func UpdateBooking(db database.Service) gin.HandlerFunc {
+func UpdateBooking(db database.Service, hub *events.Hub) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		if !exists {
-			c.JSON(401, gin.H{"error": "Unauthorized"})
+			respondError(c, CodeUnauthorized, "Unauthorized")
 			return
 		}
 
 		var booking protocol.UpdateBookingMessage
-		if err := c.ShouldBindJSON(&booking); err != nil {
-			c.JSON(400, gin.H{"error": "Invalid input"})
+		if !bindJSON(c, &booking) {
 			return
 		}
 
@@ -176,34 +267,117 @@ func UpdateBooking(db database.Service) gin.HandlerFunc {
 
 		// Check if user can access this booking
 		if !db.UserCanAccessBooking(userID.(string), bookingID) {
-			c.JSON(403, gin.H{"error": "Forbidden: You don't have access to this booking"})
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this booking")
+			return
+		}
+
+		existing, err := db.GetBookingByID(bookingID)
+		if err != nil {
+			respondError(c, CodeNotFound, "Booking not found")
+			return
+		}
+		if !checkIfMatch(c, existing.Version, newBookingResponse(existing)) {
 			return
 		}
 
 		b := database.Booking{
 			ID:         bookingID,
-			PropertyID: "", // This is not needed for update
+			CreatedAt:  existing.CreatedAt,
+			CreatedBy:  existing.CreatedBy,
+			PropertyID: existing.PropertyID,
 			StartDate:  booking.StartDate,
 			EndDate:    booking.EndDate,
 			GuestName:  booking.GuestName,
 			Adults:     booking.Adults,
 			Children:   booking.Children,
+			GuestID:    booking.GuestID,
+			Notes:      booking.Notes,
+		}
+
+		if err := linkBookingGuest(db, existing.PropertyID, &b); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		saveBooking(c, db, hub, userID.(string), existing, b)
+	}
+}
+
+// PatchBooking changes the fields present in a JSON merge patch and leaves the others as they are
+func PatchBooking(db database.Service, hub *events.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		var patch protocol.PatchBookingMessage
+		if !bindJSON(c, &patch) {
+			return
+		}
+		if patch.StartDate.Null {
+			abortWithError(c, invalidField("start_date", fieldRequired, "start_date cannot be removed"))
+			return
+		}
+		if patch.EndDate.Null {
+			abortWithError(c, invalidField("end_date", fieldRequired, "end_date cannot be removed"))
+			return
+		}
+
+		bookingID := c.Param("bookingID")
+
+		// Check if user can access this booking
+		if !db.UserCanAccessBooking(userID.(string), bookingID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this booking")
+			return
 		}
 
-		err := db.UpdateBooking(b)
+		existing, err := db.GetBookingByID(bookingID)
 		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to update booking"})
+			respondError(c, CodeNotFound, "Booking not found")
 			return
 		}
-		c.JSON(200, gin.H{"message": "Booking updated successfully"})
+		if !checkIfMatch(c, existing.Version, newBookingResponse(existing)) {
+			return
+		}
+
+		b := existing
+		patch.StartDate.Apply(&b.StartDate)
+		patch.EndDate.Apply(&b.EndDate)
+		patch.GuestName.Apply(&b.GuestName)
+		patch.Adults.Apply(&b.Adults)
+		patch.Children.Apply(&b.Children)
+		patch.GuestID.Apply(&b.GuestID)
+		patch.Notes.Apply(&b.Notes)
+
+		// Only check what changed, the rest was valid when it was stored
+		if patch.StartDate.Set || patch.EndDate.Set {
+			if b.EndDate < b.StartDate {
+				field := "end_date"
+				if !patch.EndDate.Set {
+					field = "start_date"
+				}
+				abortWithError(c, invalidField(field, fieldOutOfRange, "end_date must not be before start_date"))
+				return
+			}
+		}
+		if patch.GuestID.Set {
+			if err := linkBookingGuest(db, existing.PropertyID, &b); err != nil {
+				abortWithError(c, err)
+				return
+			}
+		}
+
+		saveBooking(c, db, hub, userID.(string), existing, b)
 	}
 }
 
-func DeleteBooking(db database.Service) gin.HandlerFunc {
+func DeleteBooking(db database.Service, blobs storage.BlobStore, hub *events.Hub) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		if !exists {
-			c.JSON(401, gin.H{"error": "Unauthorized"})
+			respondError(c, CodeUnauthorized, "Unauthorized")
 			return
 		}
 
@@ -211,15 +385,78 @@ func DeleteBooking(db database.Service) gin.HandlerFunc {
 
 		// Check if user can access this booking
 		if !db.UserCanAccessBooking(userID.(string), bookingID) {
-			c.JSON(403, gin.H{"error": "Forbidden: You don't have access to this booking"})
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this booking")
 			return
 		}
 
-		err := db.DeleteBooking(bookingID)
+		existing, err := db.GetBookingByID(bookingID)
+		if err != nil {
+			respondError(c, CodeNotFound, "Booking not found")
+			return
+		}
+		if !checkIfMatch(c, existing.Version, newBookingResponse(existing)) {
+			return
+		}
+
+		// Resolve the group before the booking is gone
+		groupID, hasGroup := bookingGroupID(db, bookingID)
+
+		attachments, err := db.DeleteBooking(bookingID, existing.Version)
+		if errors.Is(err, database.ErrVersionConflict) {
+			respondBookingConflict(c, db, bookingID)
+			return
+		}
 		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to delete booking"})
+			respondError(c, CodeInternal, "Failed to delete booking")
 			return
 		}
+		removeBlobs(blobs, attachments)
+
+		if hasGroup {
+			recordActivity(db, groupID, userID.(string), "booking.deleted", "booking", bookingID, bookingSummary(existing))
+			publish(hub, groupID, userID.(string), events.BookingDeleted, newBookingResponse(existing))
+		}
 		c.JSON(200, gin.H{"message": "Booking deleted successfully"})
 	}
 }
+
+// saveBooking stores the changes made to a booking and tells the group about them.
+// The changes must be based on existing, which the client has seen.
+func saveBooking(c *gin.Context, db database.Service, hub *events.Hub, userID string, existing, b database.Booking) {
+	b.Version = existing.Version
+	if err := db.UpdateBooking(b); err != nil {
+		if errors.Is(err, database.ErrVersionConflict) {
+			respondBookingConflict(c, db, b.ID)
+			return
+		}
+		respondError(c, CodeInternal, "Failed to update booking")
+		return
+	}
+	b.Version++
+
+	if groupID, ok := bookingGroupID(db, b.ID); ok {
+		recordActivity(db, groupID, userID, "booking.updated", "booking", b.ID, bookingSummary(b))
+		publishUpdate(hub, groupID, userID, events.BookingUpdated, newBookingResponse(existing), newBookingResponse(b))
+	}
+	c.Header("ETag", etag(b.Version))
+	c.JSON(200, newBookingResponse(b))
+}
+
+// respondBookingConflict sends the booking as it is now, after a change based on an older version failed
+func respondBookingConflict(c *gin.Context, db database.Service, bookingID string) {
+	current, err := db.GetBookingByID(bookingID)
+	if err != nil {
+		respondError(c, CodeNotFound, "Booking not found")
+		return
+	}
+	respondVersionConflict(c, current.Version, newBookingResponse(current))
+}
+
+// bookingSummary describes a booking in one line for the activity history
+func bookingSummary(b database.Booking) string {
+	summary := b.StartDate + " – " + b.EndDate
+	if b.GuestName != "" {
+		summary = b.GuestName + ", " + summary
+	}
+	return summary
+}
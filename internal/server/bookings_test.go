@@ -0,0 +1,73 @@
+package server
+
+import (
+	"booker-be/internal/database"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestListBookingsWithoutPagingReturnsEveryBooking(t *testing.T) {
+	db := database.New(filepath.Join(t.TempDir(), "bookings.db"))
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.InsertProperty(database.Property{ID: "p1", GroupID: "g1", Name: "Villa"}); err != nil {
+		t.Fatal(err)
+	}
+	// More bookings than a page, the newest of them current and upcoming
+	total := defaultBookingLimit + 20
+	start := time.Now().AddDate(0, 0, -total+5)
+	for i := range total {
+		day := start.AddDate(0, 0, i)
+		err := db.InsertBooking(database.Booking{
+			ID:         fmt.Sprintf("b%03d", i),
+			PropertyID: "p1",
+			StartDate:  day.Format("2006-01-02"),
+			EndDate:    day.AddDate(0, 0, 1).Format("2006-01-02"),
+			GuestName:  "Guest",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/bookings", func(c *gin.Context) {
+		listBookings(c, db, database.BookingFilter{GroupID: "g1"})
+	})
+
+	list := func(query string) ([]json.RawMessage, string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/bookings"+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /bookings%s: status %d: %s", query, rec.Code, rec.Body)
+		}
+		var bookings []json.RawMessage
+		if err := json.Unmarshal(rec.Body.Bytes(), &bookings); err != nil {
+			t.Fatal(err)
+		}
+		return bookings, rec.Header().Get(nextCursorHeader)
+	}
+
+	bookings, cursor := list("")
+	if len(bookings) != total || cursor != "" {
+		t.Errorf("without limit and cursor: %d bookings, cursor %q, want all %d and no cursor", len(bookings), cursor, total)
+	}
+
+	bookings, cursor = list("?limit=10")
+	if len(bookings) != 10 || cursor == "" {
+		t.Fatalf("with limit=10: %d bookings, cursor %q, want 10 and a cursor", len(bookings), cursor)
+	}
+	bookings, _ = list("?cursor=" + cursor)
+	if len(bookings) != defaultBookingLimit {
+		t.Errorf("with a cursor alone: %d bookings, want a page of %d", len(bookings), defaultBookingLimit)
+	}
+}
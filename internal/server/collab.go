@@ -0,0 +1,294 @@
+package server
+
+import (
+	"booker-be/internal/collab"
+	"booker-be/internal/cors"
+	"booker-be/internal/database"
+	"booker-be/internal/events"
+	"booker-be/internal/protocol"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	socketPingInterval   = 30 * time.Second
+	socketReadTimeout    = 75 * time.Second // Two missed heartbeats and a bit of slack
+	socketWriteTimeout   = 10 * time.Second
+	socketReplyBuffer    = 16
+	maxSocketMessageSize = 16 << 10 // 16 KB
+)
+
+// socketMessage is a message sent by a calendar client.
+//
+//	{"type": "subscribe", "property_ids": [...]}   receive booking changes of these properties only
+//	{"type": "unsubscribe", "property_ids": [...]} stop receiving them; none left means all properties
+//	{"type": "view", "month": "2025-07"}           tell the others which month is being viewed
+//	{"type": "ping"} / {"type": "pong"}            heartbeat, answered with pong / nothing
+type socketMessage struct {
+	Type        string   `json:"type"`
+	PropertyIDs []string `json:"property_ids"`
+	Month       string   `json:"month"`
+}
+
+// groupSocket is a calendar client connected to a group
+type groupSocket struct {
+	ws      *websocket.Conn
+	db      database.Service
+	groupID string
+	userID  string
+	replies chan any
+
+	mu         sync.Mutex
+	properties map[string]bool // Subscribed property IDs, empty for every property of the group
+}
+
+// GroupSocket opens a WebSocket for collaborative editing of a group's calendar.
+// Clients receive booking and property changes (with the changed fields on updates)
+// and the presence of the other viewers. As with the event stream, a reconnecting
+// client may pass ?last_event_id= to receive what it missed.
+// Browsers open WebSockets from any page, so the handshake is refused when the Origin
+// is neither the API's own nor allowed by the CORS policy.
+func GroupSocket(db database.Service, hub *events.Hub, presence *collab.Presence, origins *cors.CORS) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		groupID := c.Param("id")
+
+		// Check if user belongs to this group
+		if !db.UserBelongsToGroup(userID.(string), groupID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this group")
+			return
+		}
+
+		var lastEventID uint64
+		if lastID := c.Query("last_event_id"); lastID != "" {
+			id, err := strconv.ParseUint(lastID, 10, 64)
+			if err != nil {
+				respondError(c, CodeInvalidInput, "Invalid last_event_id")
+				return
+			}
+			lastEventID = id
+		}
+
+		user, err := db.GetUserByID(userID.(string))
+		if err != nil {
+			respondError(c, CodeInternal, "Failed to retrieve user")
+			return
+		}
+
+		server := websocket.Server{
+			// Non-browser clients send no Origin and are welcome
+			Handshake: func(_ *websocket.Config, r *http.Request) error {
+				origin := r.Header.Get("Origin")
+				if origin == "" || isSameOrigin(origin, r.Host) || origins.AllowsOrigin(r.URL.Path, origin) {
+					return nil
+				}
+				return errors.New("origin not allowed: " + origin)
+			},
+			Handler: func(ws *websocket.Conn) {
+				ws.MaxPayloadBytes = maxSocketMessageSize
+				s := &groupSocket{
+					ws:         ws,
+					db:         db,
+					groupID:    groupID,
+					userID:     user.ID,
+					replies:    make(chan any, socketReplyBuffer),
+					properties: make(map[string]bool),
+				}
+				s.serve(hub, presence, user.Username, lastEventID)
+			},
+		}
+		server.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// isSameOrigin checks if an Origin header names the host the request was sent to
+func isSameOrigin(origin, host string) bool {
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, host)
+}
+
+func (s *groupSocket) serve(hub *events.Hub, presence *collab.Presence, username string, lastEventID uint64) {
+	sub, missed := hub.Subscribe(s.groupID, lastEventID)
+	defer sub.Close()
+
+	session := presence.Join(s.groupID, collab.Viewer{UserID: s.userID, Username: username})
+	defer session.Leave()
+
+	done := make(chan struct{})
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		s.write(sub, session, missed, done)
+		// Unblock the reader if the writer gave up first
+		s.ws.Close()
+	}()
+
+	s.read(session)
+	close(done)
+	<-writerDone
+}
+
+// read handles client messages until the connection fails or goes quiet
+func (s *groupSocket) read(session *collab.Session) {
+	for {
+		if err := s.ws.SetReadDeadline(time.Now().Add(socketReadTimeout)); err != nil {
+			return
+		}
+
+		var msg socketMessage
+		if err := websocket.JSON.Receive(s.ws, &msg); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+				s.reply(gin.H{"type": "error", "error": "Invalid message"})
+				continue
+			}
+			return
+		}
+
+		switch msg.Type {
+		case "ping":
+			s.reply(gin.H{"type": "pong"})
+		case "pong":
+			// Receiving it already extended the read deadline
+		case "subscribe", "unsubscribe":
+			if msg.Type == "subscribe" && !s.ownsProperties(msg.PropertyIDs) {
+				s.reply(gin.H{"type": "error", "error": "Property not found in this group"})
+				continue
+			}
+			s.reply(gin.H{"type": "subscribed", "property_ids": s.subscribe(msg.PropertyIDs, msg.Type == "subscribe")})
+		case "view":
+			if _, err := time.Parse("2006-01", msg.Month); err != nil {
+				s.reply(gin.H{"type": "error", "error": "Invalid month, expected YYYY-MM"})
+				continue
+			}
+			session.SetMonth(msg.Month)
+		default:
+			s.reply(gin.H{"type": "error", "error": "Unknown message type"})
+		}
+	}
+}
+
+// write sends events, presence updates, replies and heartbeats until done is closed or a write fails
+func (s *groupSocket) write(sub *events.Subscription, session *collab.Session, missed []events.Event, done <-chan struct{}) {
+	if !s.send(gin.H{"type": "welcome", "user_id": s.userID}) {
+		return
+	}
+	for _, e := range missed {
+		if s.wants(e) && !s.send(e) {
+			return
+		}
+	}
+
+	ping := time.NewTicker(socketPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				// Dropped by the hub for falling behind; the client reconnects
+				// with its last event ID and catches up from the history
+				s.send(gin.H{"type": "error", "error": "Too far behind, reconnect to resume"})
+				return
+			}
+			if s.wants(e) && !s.send(e) {
+				return
+			}
+		case <-session.Changed():
+			// Presence is sent as a snapshot, so bursts of changes collapse into one message
+			if !s.send(gin.H{"type": "presence", "viewers": session.Viewers()}) {
+				return
+			}
+		case r := <-s.replies:
+			if !s.send(r) {
+				return
+			}
+		case <-ping.C:
+			if !s.send(gin.H{"type": "ping"}) {
+				return
+			}
+		}
+	}
+}
+
+func (s *groupSocket) send(v any) bool {
+	if err := s.ws.SetWriteDeadline(time.Now().Add(socketWriteTimeout)); err != nil {
+		return false
+	}
+	return websocket.JSON.Send(s.ws, v) == nil
+}
+
+// reply queues a response to a client message. A client that sends faster than
+// it reads loses replies instead of stalling the connection.
+func (s *groupSocket) reply(v any) {
+	select {
+	case s.replies <- v:
+	default:
+	}
+}
+
+// wants reports whether the client subscribed to the property an event is about
+func (s *groupSocket) wants(e events.Event) bool {
+	var propertyID string
+	switch data := e.Data.(type) {
+	case protocol.BookingResponse:
+		propertyID = data.PropertyID
+	case protocol.PropertyResponse:
+		propertyID = data.ID
+	default:
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.properties) == 0 || s.properties[propertyID]
+}
+
+// subscribe adds or removes property IDs and returns the resulting subscription
+func (s *groupSocket) subscribe(propertyIDs []string, add bool) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range propertyIDs {
+		if add {
+			s.properties[id] = true
+		} else {
+			delete(s.properties, id)
+		}
+	}
+
+	ids := make([]string, 0, len(s.properties))
+	for id := range s.properties {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// ownsProperties checks that every property belongs to the socket's group
+func (s *groupSocket) ownsProperties(propertyIDs []string) bool {
+	for _, id := range propertyIDs {
+		property, err := s.db.GetPropertyByID(id)
+		if err != nil || property.GroupID != s.groupID {
+			return false
+		}
+	}
+	return true
+}
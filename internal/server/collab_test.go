@@ -0,0 +1,37 @@
+package server
+
+import (
+	"booker-be/internal/database"
+	"booker-be/internal/events"
+	"testing"
+)
+
+func TestGroupSocketWantsSubscribedProperties(t *testing.T) {
+	s := &groupSocket{properties: map[string]bool{}}
+	// Events carry the resources as the handlers publish them
+	bookingA := events.Event{Type: events.BookingCreated, Data: newBookingResponse(database.Booking{ID: "b1", PropertyID: "A"})}
+	bookingB := events.Event{Type: events.BookingCreated, Data: newBookingResponse(database.Booking{ID: "b2", PropertyID: "B"})}
+	propertyB := events.Event{Type: events.PropertyUpdated, Data: newPropertyResponse(database.Property{ID: "B"})}
+
+	for _, e := range []events.Event{bookingA, bookingB, propertyB} {
+		if !s.wants(e) {
+			t.Errorf("without a subscription the socket doesn't want %s about %v", e.Type, e.Data)
+		}
+	}
+
+	s.subscribe([]string{"A"}, true)
+	if !s.wants(bookingA) {
+		t.Error("a socket subscribed to A doesn't want A's booking")
+	}
+	if s.wants(bookingB) {
+		t.Error("a socket subscribed to A wants B's booking")
+	}
+	if s.wants(propertyB) {
+		t.Error("a socket subscribed to A wants B's property update")
+	}
+
+	s.subscribe([]string{"A"}, false)
+	if !s.wants(bookingB) {
+		t.Error("after unsubscribing from every property the socket doesn't want B's booking")
+	}
+}
@@ -0,0 +1,246 @@
+package server
+
+import (
+	"booker-be/internal/database"
+	"booker-be/internal/protocol"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetBookingComments returns the comments of a booking as threads, oldest first
+func GetBookingComments(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		bookingID := c.Param("bookingID")
+
+		// Check if user can access this booking
+		if !db.UserCanAccessBooking(userID.(string), bookingID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this booking")
+			return
+		}
+
+		comments, err := db.GetBookingCommentsByBookingID(bookingID)
+		if err != nil {
+			respondError(c, CodeInternal, "Failed to retrieve comments")
+			return
+		}
+
+		threads := make(map[string]*protocol.CommentThreadResponse, len(comments))
+		for _, comment := range comments {
+			threads[comment.ID] = &protocol.CommentThreadResponse{CommentResponse: newCommentResponse(comment), Replies: []*protocol.CommentThreadResponse{}}
+		}
+
+		roots := []*protocol.CommentThreadResponse{}
+		for _, comment := range comments {
+			thread := threads[comment.ID]
+			if parent, ok := threads[comment.ParentID]; ok {
+				parent.Replies = append(parent.Replies, thread)
+				continue
+			}
+			roots = append(roots, thread)
+		}
+		c.JSON(200, roots)
+	}
+}
+
+// GetBookingComment returns one comment of a booking, without its replies
+func GetBookingComment(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		bookingID := c.Param("bookingID")
+
+		// Check if user can access this booking
+		if !db.UserCanAccessBooking(userID.(string), bookingID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this booking")
+			return
+		}
+
+		comment, err := db.GetBookingCommentByID(c.Param("commentID"))
+		if err != nil || comment.BookingID != bookingID || comment.Deleted {
+			respondError(c, CodeNotFound, "Comment not found")
+			return
+		}
+		c.JSON(200, newCommentResponse(comment))
+	}
+}
+
+func CreateBookingComment(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		var comment protocol.CreateCommentMessage
+		if !bindJSON(c, &comment) {
+			return
+		}
+
+		bookingID := c.Param("bookingID")
+
+		// Check if user can access this booking
+		if !db.UserCanAccessBooking(userID.(string), bookingID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this booking")
+			return
+		}
+
+		comment.Body = strings.TrimSpace(comment.Body)
+
+		if comment.ParentID != "" {
+			parent, err := db.GetBookingCommentByID(comment.ParentID)
+			if err != nil || parent.BookingID != bookingID {
+				abortWithError(c, invalidField("parent_id", fieldInvalidValue, "Parent comment not found on this booking"))
+				return
+			}
+		}
+
+		now := protocol.GetCurrentTime()
+		bc := database.BookingComment{
+			ID:        protocol.GenerateID(),
+			CreatedAt: now,
+			UpdatedAt: now,
+			BookingID: bookingID,
+			ParentID:  comment.ParentID,
+			AuthorID:  userID.(string),
+			Body:      comment.Body,
+		}
+
+		if err := db.InsertBookingComment(bc); err != nil {
+			respondError(c, CodeInternal, "Failed to create comment")
+			return
+		}
+
+		if groupID, ok := bookingGroupID(db, bookingID); ok {
+			recordActivity(db, groupID, bc.AuthorID, "comment.created", "booking", bookingID, commentSummary(bc.Body))
+		}
+
+		// Read back for the author's name
+		created, err := db.GetBookingCommentByID(bc.ID)
+		if err != nil {
+			respondError(c, CodeInternal, "Failed to retrieve comment")
+			return
+		}
+		respondCreated(c, "/bookings/"+bookingID+"/comments/"+bc.ID, newCommentResponse(created))
+	}
+}
+
+func UpdateBookingComment(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		var update protocol.UpdateCommentMessage
+		if !bindJSON(c, &update) {
+			return
+		}
+
+		bookingID := c.Param("bookingID")
+		existing, ok := commentForAuthor(c, db, userID.(string), bookingID)
+		if !ok {
+			return
+		}
+
+		update.Body = strings.TrimSpace(update.Body)
+
+		existing.Body = update.Body
+		existing.UpdatedAt = protocol.GetCurrentTime()
+		if err := db.UpdateBookingComment(existing); err != nil {
+			respondError(c, CodeInternal, "Failed to update comment")
+			return
+		}
+
+		if groupID, ok := bookingGroupID(db, bookingID); ok {
+			recordActivity(db, groupID, existing.AuthorID, "comment.updated", "booking", bookingID, commentSummary(existing.Body))
+		}
+
+		c.JSON(200, newCommentResponse(existing))
+	}
+}
+
+func DeleteBookingComment(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		bookingID := c.Param("bookingID")
+		existing, ok := commentForAuthor(c, db, userID.(string), bookingID)
+		if !ok {
+			return
+		}
+
+		if err := db.DeleteBookingComment(existing.ID, protocol.GetCurrentTime()); err != nil {
+			respondError(c, CodeInternal, "Failed to delete comment")
+			return
+		}
+
+		if groupID, ok := bookingGroupID(db, bookingID); ok {
+			recordActivity(db, groupID, existing.AuthorID, "comment.deleted", "booking", bookingID, "")
+		}
+
+		c.JSON(200, gin.H{"message": "Comment deleted successfully"})
+	}
+}
+
+// commentForAuthor loads the comment named in the URL and checks that it belongs to the
+// booking and was written by the user. On failure it writes the response and returns false.
+func commentForAuthor(c *gin.Context, db database.Service, userID, bookingID string) (database.BookingComment, bool) {
+	// Check if user can access this booking
+	if !db.UserCanAccessBooking(userID, bookingID) {
+		respondError(c, CodeForbidden, "Forbidden: You don't have access to this booking")
+		return database.BookingComment{}, false
+	}
+
+	comment, err := db.GetBookingCommentByID(c.Param("commentID"))
+	if err != nil || comment.BookingID != bookingID || comment.Deleted {
+		respondError(c, CodeNotFound, "Comment not found")
+		return database.BookingComment{}, false
+	}
+
+	if comment.AuthorID != userID {
+		respondError(c, CodeForbidden, "Forbidden: Only the author can change this comment")
+		return database.BookingComment{}, false
+	}
+
+	return comment, true
+}
+
+// bookingGroupID looks up the group owning the property a booking is for
+func bookingGroupID(db database.Service, bookingID string) (string, bool) {
+	booking, err := db.GetBookingByID(bookingID)
+	if err != nil {
+		return "", false
+	}
+	property, err := db.GetPropertyByID(booking.PropertyID)
+	if err != nil {
+		return "", false
+	}
+	return property.GroupID, true
+}
+
+// commentSummary shortens a comment body for the activity history
+func commentSummary(body string) string {
+	const maxRunes = 80
+	runes := []rune(body)
+	if len(runes) <= maxRunes {
+		return body
+	}
+	return string(runes[:maxRunes]) + "…"
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorCode identifies an error for clients, which branch on it and translate it.
+// Codes are part of the API: never change the meaning of an existing one.
+type ErrorCode string
+
+const (
+	CodeInvalidInput         ErrorCode = "invalid_input"     // Malformed body, query or path parameter
+	CodeValidationFailed     ErrorCode = "validation_failed" // Well-formed input with invalid fields, see Details
+	CodeUnauthorized         ErrorCode = "unauthorized"
+	CodeInvalidCredentials   ErrorCode = "invalid_credentials"
+	CodeForbidden            ErrorCode = "forbidden"
+	CodeNotFound             ErrorCode = "not_found"
+	CodeConflict             ErrorCode = "conflict"
+	CodeUsernameTaken        ErrorCode = "username_taken"
+	CodeAlreadyMember        ErrorCode = "already_member"
+	CodeGroupCodeExpired     ErrorCode = "group_code_expired"
+	CodeRequestInProgress    ErrorCode = "request_in_progress"    // A request with the same Idempotency-Key is still being handled
+	CodeIdempotencyKeyReused ErrorCode = "idempotency_key_reused" // The Idempotency-Key was used for a different request
+	CodeVersionConflict      ErrorCode = "version_conflict"       // If-Match doesn't match, see Current
+	CodePreconditionRequired ErrorCode = "precondition_required"
+	CodePayloadTooLarge      ErrorCode = "payload_too_large"
+	CodeUnsupportedMediaType ErrorCode = "unsupported_media_type"
+	CodeUnprocessable        ErrorCode = "unprocessable"
+	CodeInternal             ErrorCode = "internal_error"
+)
+
+// errorStatus maps every error code to its HTTP status
+var errorStatus = map[ErrorCode]int{
+	CodeInvalidInput:         400,
+	CodeValidationFailed:     400,
+	CodeUnauthorized:         401,
+	CodeInvalidCredentials:   401,
+	CodeForbidden:            403,
+	CodeNotFound:             404,
+	CodeConflict:             409,
+	CodeUsernameTaken:        409,
+	CodeAlreadyMember:        409,
+	CodeGroupCodeExpired:     410,
+	CodeRequestInProgress:    409,
+	CodeIdempotencyKeyReused: 422,
+	CodeVersionConflict:      412,
+	CodePreconditionRequired: 428,
+	CodePayloadTooLarge:      413,
+	CodeUnsupportedMediaType: 415,
+	CodeUnprocessable:        422,
+	CodeInternal:             500,
+}
+
+// APIError is the body of every error response, sent as {"error": {...}}.
+// Message is meant for developers; clients show a translation of Code.
+type APIError struct {
+	Code      ErrorCode    `json:"code"`
+	Message   string       `json:"message"`
+	Details   []FieldError `json:"details,omitempty"`
+	Current   any          `json:"current,omitempty"` // The stored resource, on version conflicts
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+// FieldError explains why one field of the input is invalid
+type FieldError struct {
+	Field   string `json:"field"` // JSON name, or form field name for uploads
+	Code    string `json:"code"`  // One of the field* codes
+	Message string `json:"message"`
+}
+
+// Field error codes
+const (
+	fieldRequired      = "required"
+	fieldInvalidFormat = "invalid_format"
+	fieldInvalidValue  = "invalid_value"
+	fieldTooShort      = "too_short"
+	fieldTooLong       = "too_long"
+	fieldOutOfRange    = "out_of_range"
+)
+
+func (e *APIError) Error() string {
+	return string(e.Code) + ": " + e.Message
+}
+
+// Status returns the HTTP status of the error
+func (e *APIError) Status() int {
+	if status, ok := errorStatus[e.Code]; ok {
+		return status
+	}
+	return 500
+}
+
+// NewError creates an error without details
+func NewError(code ErrorCode, message string) *APIError {
+	return &APIError{Code: code, Message: message}
+}
+
+// invalidField creates a validation error for a single field
+func invalidField(field, code, message string) *APIError {
+	return &APIError{
+		Code:    CodeValidationFailed,
+		Message: message,
+		Details: []FieldError{{Field: field, Code: code, Message: message}},
+	}
+}
+
+// abortWithError sends err with its status and stops the handler chain
+func abortWithError(c *gin.Context, err *APIError) {
+	body := *err
+	body.RequestID = c.GetString(requestIDKey)
+	c.AbortWithStatusJSON(body.Status(), gin.H{"error": body})
+}
+
+// respondError sends an error without details
+func respondError(c *gin.Context, code ErrorCode, message string) {
+	abortWithError(c, NewError(code, message))
+}
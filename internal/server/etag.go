@@ -0,0 +1,71 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Bookings, properties and groups carry a version that every change increments. Their ETag
+// is the quoted version, and changing or deleting them requires it in the If-Match header,
+// so that one client doesn't silently overwrite the changes of another.
+
+func etag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// respondWithVersion sends a resource with its ETag, or 304 when the client's copy is current
+func respondWithVersion(c *gin.Context, version int, resource any) {
+	tag := etag(version)
+	c.Header("ETag", tag)
+	if etagListContains(c.GetHeader("If-None-Match"), tag, true) {
+		c.Status(304)
+		return
+	}
+	c.JSON(200, resource)
+}
+
+// checkIfMatch checks that the client changes the version it has read. It responds
+// 428 when the If-Match header is missing and 412 with the current resource when
+// it doesn't match, and returns false in both cases.
+func checkIfMatch(c *gin.Context, version int, current any) bool {
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		respondError(c, CodePreconditionRequired, "The If-Match header is required, with the ETag of the version to change")
+		return false
+	}
+	if !etagListContains(ifMatch, etag(version), false) {
+		respondVersionConflict(c, version, current)
+		return false
+	}
+	return true
+}
+
+// respondVersionConflict tells the client the resource has changed and sends the current state to merge with
+func respondVersionConflict(c *gin.Context, version int, current any) {
+	c.Header("ETag", etag(version))
+	abortWithError(c, &APIError{
+		Code:    CodeVersionConflict,
+		Message: "The resource has been changed by someone else",
+		Current: current,
+	})
+}
+
+// etagListContains reports whether an If-Match or If-None-Match header matches tag.
+// If-Match compares strongly, so weak ETags never match it.
+func etagListContains(header, tag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"booker-be/internal/database"
+	"booker-be/internal/events"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// Comments are sent this often on an idle stream so proxies keep the connection open
+const streamKeepAlive = 25 * time.Second
+
+// StreamGroupEvents streams the booking and property changes of a group as Server-Sent Events.
+// A reconnecting client may send Last-Event-ID (or ?last_event_id=) to receive what it missed.
+func StreamGroupEvents(db database.Service, hub *events.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		groupID := c.Param("id")
+
+		// Check if user belongs to this group
+		if !db.UserBelongsToGroup(userID.(string), groupID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this group")
+			return
+		}
+
+		lastID := c.GetHeader("Last-Event-ID")
+		if lastID == "" {
+			lastID = c.Query("last_event_id")
+		}
+		var lastEventID uint64
+		if lastID != "" {
+			id, err := strconv.ParseUint(lastID, 10, 64)
+			if err != nil {
+				respondError(c, CodeInvalidInput, "Invalid Last-Event-ID")
+				return
+			}
+			lastEventID = id
+		}
+
+		// The stream outlives the server's write timeout
+		_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+		sub, missed := hub.Subscribe(groupID, lastEventID)
+		defer sub.Close()
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no") // Disable response buffering in nginx
+		c.Status(200)
+
+		for _, e := range missed {
+			writeEvent(c, e)
+		}
+		c.Writer.Flush()
+
+		keepAlive := time.NewTicker(streamKeepAlive)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case e, ok := <-sub.C:
+				if !ok {
+					// Dropped for falling behind or the server is shutting down;
+					// the client reconnects and resumes from its last event
+					return
+				}
+				writeEvent(c, e)
+				c.Writer.Flush()
+			case <-keepAlive.C:
+				if _, err := c.Writer.WriteString(": ping\n\n"); err != nil {
+					return
+				}
+				c.Writer.Flush()
+			}
+		}
+	}
+}
+
+func writeEvent(c *gin.Context, e events.Event) {
+	c.Render(-1, sse.Event{
+		Id:    strconv.FormatUint(e.ID, 10),
+		Event: e.Type,
+		Data:  e,
+	})
+}
+
+// publishUpdate is like publish and also lists the fields that changed
+func publishUpdate(hub *events.Hub, groupID, actorID, eventType string, before, after any) {
+	hub.Publish(events.Event{
+		Type:    eventType,
+		GroupID: groupID,
+		ActorID: actorID,
+		Data:    after,
+		Changes: events.Diff(before, after),
+	})
+}
+
+// publish notifies the group's subscribers about a change made by a user. Event streams
+// and webhooks send data as is, so it is the resource's response, never a database model.
+func publish(hub *events.Hub, groupID, actorID, eventType string, data any) {
+	hub.Publish(events.Event{
+		Type:    eventType,
+		GroupID: groupID,
+		ActorID: actorID,
+		Data:    data,
+	})
+}
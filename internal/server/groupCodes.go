@@ -9,10 +9,6 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-var (
-	groupCodeDuration = 24 * 60 * 60 // 24 hours in seconds
-)
-
 func generateGroupCode() string {
 	code := make([]rune, 6) // Generate a 6-character code
 	for i := range code {
@@ -22,11 +18,10 @@ func generateGroupCode() string {
 	return string(code)
 }
 
-func CreateGroupCode(db database.Service) gin.HandlerFunc {
+func CreateGroupCode(db database.Service, codeDuration time.Duration) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var groupCode protocol.GroupCodeMessage
-		if err := c.ShouldBindJSON(&groupCode); err != nil {
-			c.JSON(400, gin.H{"error": "Invalid input"})
+		if !bindJSON(c, &groupCode) {
 			return
 		}
 
@@ -34,17 +29,15 @@ func CreateGroupCode(db database.Service) gin.HandlerFunc {
 			ID:       protocol.GenerateID(),
 			Code:     generateGroupCode(),
 			GroupID:  groupCode.GroupID,
-			ActiveTo: (time.Now().Add(time.Duration(groupCodeDuration) * time.Second)).Format(time.RFC3339),
+			ActiveTo: time.Now().Add(codeDuration).Format(time.RFC3339),
 		}
 
 		err := db.InsertGroupCode(gCode)
 		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to create group code"})
+			respondError(c, CodeInternal, "Failed to create group code")
 			return
 		}
-		c.JSON(201, gin.H{
-			"message":   "Group code created successfully",
-			"groupCode": gCode.Code,
-		})
+		// Codes are only used to join, see JoinGroup, so there is no Location
+		c.JSON(201, newGroupCodeResponse(gCode))
 	}
 }
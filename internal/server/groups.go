@@ -9,10 +9,11 @@ import (
 
 func GetGroupsByUserID(db database.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID := c.Param("userID")
+		// The parameter is named "id" since /groups/:id/events shares this path segment
+		userID := c.Param("id")
 		groupUsers, err := db.GetAllGroupUsersByUserID(userID)
 		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to retrieve groups"})
+			respondError(c, CodeInternal, "Failed to retrieve groups")
 			return
 		}
 
@@ -22,31 +23,54 @@ func GetGroupsByUserID(db database.Service) gin.HandlerFunc {
 		}
 		groups, err := db.GetGroupsByID(gIds)
 		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to retrieve groups"})
+			respondError(c, CodeInternal, "Failed to retrieve groups")
 			return
 		}
 
-		c.JSON(200, groups)
+		c.JSON(200, mapAll(groups, newGroupResponse))
+	}
+}
+
+// GetGroup returns a group with its version as ETag
+func GetGroup(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get(authorizationPayloadKey)
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		group, err := db.GetGroupByID(c.Param("groupID"))
+		if err != nil {
+			respondError(c, CodeNotFound, "Group not found")
+			return
+		}
+
+		if !db.UserBelongsToGroup(userID.(string), group.ID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this group")
+			return
+		}
+
+		respondWithVersion(c, group.Version, newGroupResponse(group))
 	}
 }
 
 func CreateGroup(db database.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var group protocol.GroupCreateMessage
-		if err := c.ShouldBindJSON(&group); err != nil {
-			c.JSON(400, gin.H{"error": "Invalid input"})
+		if !bindJSON(c, &group) {
 			return
 		}
 
 		userID, exists := c.Get(authorizationPayloadKey)
 		if !exists {
-			c.JSON(401, gin.H{"error": "Unauthorized"})
+			respondError(c, CodeUnauthorized, "Unauthorized")
 			return
 		}
 
 		uID, ok := userID.(string)
 		if !ok {
-			c.JSON(401, gin.H{"error": "Unauthorized"})
+			respondError(c, CodeUnauthorized, "Unauthorized")
 			return
 		}
 
@@ -55,11 +79,12 @@ func CreateGroup(db database.Service) gin.HandlerFunc {
 			Name:      group.Name,
 			OwnerID:   uID,
 			CreatedAt: protocol.GetCurrentTime(),
+			Version:   1,
 		}
 
 		err := db.InsertGroup(g)
 		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to create group"})
+			respondError(c, CodeInternal, "Failed to create group")
 			return
 		}
 
@@ -71,11 +96,12 @@ func CreateGroup(db database.Service) gin.HandlerFunc {
 
 		err = db.InsertGroupUser(groupUser)
 		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to add user to group"})
+			respondError(c, CodeInternal, "Failed to add user to group")
 			return
 		}
 
-		c.JSON(201, gin.H{"message": "Group created successfully"})
+		c.Header("ETag", etag(g.Version))
+		respondCreated(c, "/groups/group/"+g.ID, newGroupResponse(g))
 	}
 }
 
@@ -84,30 +110,30 @@ func JoinGroup(db database.Service) gin.HandlerFunc {
 		code := c.Param("code")
 		userID, exists := c.Get(authorizationPayloadKey)
 		if !exists {
-			c.JSON(401, gin.H{"error": "Unauthorized"})
+			respondError(c, CodeUnauthorized, "Unauthorized")
 			return
 		}
 		uID, ok := userID.(string)
 		if !ok {
-			c.JSON(401, gin.H{"error": "Unauthorized"})
+			respondError(c, CodeUnauthorized, "Unauthorized")
 			return
 		}
 
 		groupCode, err := db.GetGroupCodeByCode(code)
 		if err != nil {
-			c.JSON(404, gin.H{"error": "Group code not found"})
+			respondError(c, CodeNotFound, "Group code not found")
 			return
 		}
 
 		if groupCode.ActiveTo < protocol.GetCurrentTime() {
-			c.JSON(400, gin.H{"error": "Group code has expired"})
+			respondError(c, CodeGroupCodeExpired, "Group code has expired")
 			return
 		}
 
 		// Check if the user is already a member of the group
 		_, err = db.GetGroupUserByUserIDAndGroupID(uID, groupCode.GroupID)
 		if err == nil {
-			c.JSON(400, gin.H{"error": "User is already a member of this group"})
+			respondError(c, CodeAlreadyMember, "User is already a member of this group")
 			return
 		}
 
@@ -118,10 +144,15 @@ func JoinGroup(db database.Service) gin.HandlerFunc {
 		}
 		err = db.InsertGroupUser(groupUser)
 		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to join group"})
+			respondError(c, CodeInternal, "Failed to join group")
 			return
 		}
 
-		c.JSON(200, gin.H{"message": "Joined group successfully"})
+		group, err := db.GetGroupByID(groupCode.GroupID)
+		if err != nil {
+			respondError(c, CodeInternal, "Failed to retrieve group")
+			return
+		}
+		c.JSON(200, newGroupResponse(group))
 	}
 }
@@ -0,0 +1,269 @@
+package server
+
+import (
+	"booker-be/internal/database"
+	"booker-be/internal/protocol"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetGuestsByGroupID(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		groupID := c.Param("groupID")
+
+		// Check if user belongs to this group
+		if !db.UserBelongsToGroup(userID.(string), groupID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this group")
+			return
+		}
+
+		guests, err := db.GetGuestsByGroupID(groupID)
+		if err != nil {
+			respondError(c, CodeInternal, "Failed to retrieve guests")
+			return
+		}
+		c.JSON(200, mapAll(guests, newGuestResponse))
+	}
+}
+
+// SearchGuests finds guests in a group's directory (?q=)
+func SearchGuests(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		groupID := c.Param("groupID")
+
+		// Check if user belongs to this group
+		if !db.UserBelongsToGroup(userID.(string), groupID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this group")
+			return
+		}
+
+		query := strings.TrimSpace(c.Query("q"))
+		if query == "" {
+			abortWithError(c, invalidField("q", fieldRequired, "Search query is required"))
+			return
+		}
+
+		guests, err := db.SearchGuests(groupID, query)
+		if err != nil {
+			respondError(c, CodeInternal, "Failed to search guests")
+			return
+		}
+		c.JSON(200, mapAll(guests, newGuestResponse))
+	}
+}
+
+// GetGuestByID returns a guest together with their stay history and totals
+func GetGuestByID(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		guestID := c.Param("guestID")
+
+		// Check if user can access this guest
+		if !db.UserCanAccessGuest(userID.(string), guestID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this guest")
+			return
+		}
+
+		guest, err := db.GetGuestByID(guestID)
+		if err != nil {
+			respondError(c, CodeNotFound, "Guest not found")
+			return
+		}
+
+		stays, err := db.GetBookingsByGuestID(guestID)
+		if err != nil {
+			respondError(c, CodeInternal, "Failed to retrieve stays")
+			return
+		}
+
+		today := time.Now().Format("2006-01-02")
+		nights, upcoming := 0, 0
+		firstStay, lastStay := "", ""
+		for _, b := range stays {
+			sD, errS := protocol.ParseDate(b.StartDate)
+			eD, errE := protocol.ParseDate(b.EndDate)
+			if errS == nil && errE == nil && eD.After(sD) {
+				nights += int(eD.Sub(sD).Hours() / 24)
+			}
+			if b.StartDate > today {
+				upcoming++
+				continue
+			}
+			if firstStay == "" || b.StartDate < firstStay {
+				firstStay = b.StartDate
+			}
+			if b.StartDate > lastStay {
+				lastStay = b.StartDate
+			}
+		}
+
+		c.JSON(200, protocol.GuestDetailResponse{
+			Guest: newGuestResponse(guest),
+			Stays: mapAll(stays, newBookingResponse),
+			Totals: protocol.GuestStayTotals{
+				Stays:         len(stays),
+				Nights:        nights,
+				UpcomingStays: upcoming,
+				FirstStay:     firstStay,
+				LastStay:      lastStay,
+			},
+		})
+	}
+}
+
+func CreateGuest(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		var guest protocol.GuestMessage
+		if !bindJSON(c, &guest) {
+			return
+		}
+
+		groupID := c.Param("groupID")
+
+		// Check if user belongs to this group
+		if !db.UserBelongsToGroup(userID.(string), groupID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this group")
+			return
+		}
+
+		guest.Name = strings.TrimSpace(guest.Name)
+
+		g := database.Guest{
+			ID:             protocol.GenerateID(),
+			CreatedAt:      protocol.GetCurrentTime(),
+			GroupID:        groupID,
+			Name:           guest.Name,
+			Email:          guest.Email,
+			Phone:          guest.Phone,
+			Country:        guest.Country,
+			DocumentNumber: guest.DocumentNumber,
+			Notes:          guest.Notes,
+		}
+
+		if err := db.InsertGuest(g); err != nil {
+			respondError(c, CodeInternal, "Failed to create guest")
+			return
+		}
+		respondCreated(c, "/guests/"+g.ID, newGuestResponse(g))
+	}
+}
+
+func UpdateGuest(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		var guest protocol.GuestMessage
+		if !bindJSON(c, &guest) {
+			return
+		}
+
+		guestID := c.Param("guestID")
+
+		// Check if user can access this guest
+		if !db.UserCanAccessGuest(userID.(string), guestID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this guest")
+			return
+		}
+
+		guest.Name = strings.TrimSpace(guest.Name)
+
+		g := database.Guest{
+			ID:             guestID,
+			Name:           guest.Name,
+			Email:          guest.Email,
+			Phone:          guest.Phone,
+			Country:        guest.Country,
+			DocumentNumber: guest.DocumentNumber,
+			Notes:          guest.Notes,
+		}
+
+		if err := db.UpdateGuest(g); err != nil {
+			respondError(c, CodeInternal, "Failed to update guest")
+			return
+		}
+
+		updated, err := db.GetGuestByID(guestID)
+		if err != nil {
+			respondError(c, CodeNotFound, "Guest not found")
+			return
+		}
+		c.JSON(200, newGuestResponse(updated))
+	}
+}
+
+func DeleteGuest(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		guestID := c.Param("guestID")
+
+		// Check if user can access this guest
+		if !db.UserCanAccessGuest(userID.(string), guestID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this guest")
+			return
+		}
+
+		if err := db.DeleteGuest(guestID); err != nil {
+			respondError(c, CodeInternal, "Failed to delete guest")
+			return
+		}
+		c.JSON(200, gin.H{"message": "Guest deleted successfully"})
+	}
+}
+
+// linkBookingGuest checks that a booking's guest belongs to the group owning the
+// booked property, and fills in the guest name when the booking has none.
+// It returns nil if the guest can be linked.
+func linkBookingGuest(db database.Service, propertyID string, b *database.Booking) *APIError {
+	if b.GuestID == "" {
+		return nil
+	}
+
+	guest, err := db.GetGuestByID(b.GuestID)
+	if err != nil {
+		return invalidField("guest_id", fieldInvalidValue, "Guest not found")
+	}
+
+	property, err := db.GetPropertyByID(propertyID)
+	if err != nil || property.GroupID != guest.GroupID {
+		return invalidField("guest_id", fieldInvalidValue, "Guest does not belong to this property's group")
+	}
+
+	if b.GuestName == "" {
+		b.GuestName = guest.Name
+	}
+	return nil
+}
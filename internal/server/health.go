@@ -0,0 +1,75 @@
+package server
+
+import (
+	"booker-be/internal/database"
+	"booker-be/internal/jobs"
+	"booker-be/internal/version"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const readinessTimeout = 2 * time.Second
+
+// checkResult is the outcome of one readiness check
+type checkResult struct {
+	Status     string `json:"status"` // "ok" or "failed"
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// GetHealth reports that the process is alive and serving requests
+func GetHealth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, gin.H{"status": "ok"})
+	}
+}
+
+// GetReadiness reports whether the server can handle traffic: the database answers,
+// its schema is up to date and the background jobs are running. It responds 503
+// when any check fails, so the orchestrator stops routing requests here.
+func GetReadiness(db database.Service, scheduler *jobs.Scheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+		defer cancel()
+
+		checks := map[string]checkResult{
+			"database":   runCheck(func() error { return db.Ping(ctx) }),
+			"migrations": runCheck(func() error { return db.CheckSchema(ctx) }),
+			"jobs": runCheck(func() error {
+				if !scheduler.Running() {
+					return errors.New("scheduler is not running")
+				}
+				return nil
+			}),
+		}
+
+		status, code := "ok", 200
+		for _, check := range checks {
+			if check.Status != "ok" {
+				status, code = "unavailable", 503
+			}
+		}
+		c.JSON(code, gin.H{"status": status, "checks": checks})
+	}
+}
+
+// GetVersion returns the version and commit of the running build
+func GetVersion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, version.Get())
+	}
+}
+
+func runCheck(check func() error) checkResult {
+	start := time.Now()
+	err := check()
+	result := checkResult{Status: "ok", DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+	}
+	return result
+}
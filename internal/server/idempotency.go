@@ -0,0 +1,186 @@
+package server
+
+import (
+	"booker-be/internal/database"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Clients that retry POST requests, such as the mobile app on a flaky connection,
+// send the same Idempotency-Key header with every attempt. The first attempt is
+// handled and its response stored; retries get that response back instead of
+// creating the resource again.
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	replayedHeader       = "Idempotent-Replayed"
+	maxIdempotencyKeyLen = 255
+	// The largest body a POST route accepts, an attachment in its multipart envelope
+	maxIdempotentBodySize = maxAttachmentSize + 1<<20
+)
+
+// Response headers replayed along with the status and body
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// IdempotencyMiddleware replays the response to a POST request when it is retried
+// with the same Idempotency-Key within ttl. A key reused for a different request
+// is rejected with 422, and a retry arriving while the first attempt is still
+// being handled with 409. Keys are scoped to the user, so it runs after
+// AuthMiddleware on routes that require a session.
+func IdempotencyMiddleware(db database.Service, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+		if !isValidIdempotencyKey(key) {
+			respondError(c, CodeInvalidInput, "The Idempotency-Key header must be at most "+strconv.Itoa(maxIdempotencyKeyLen)+" printable characters")
+			return
+		}
+
+		// The body is read here to fingerprint the request, and put back for the handler
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentBodySize))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				respondError(c, CodePayloadTooLarge, "Request body is too large")
+				return
+			}
+			respondError(c, CodeInvalidInput, "Failed to read the request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		now := time.Now()
+		record := database.IdempotencyKey{
+			UserID:      c.GetString(authorizationPayloadKey), // Empty on public routes
+			Key:         key,
+			Fingerprint: requestFingerprint(c.Request, body),
+			CreatedAt:   now.Unix(),
+		}
+		reserved, err := db.ReserveIdempotencyKey(record, now.Add(-ttl).Unix())
+		if err != nil {
+			c.Error(err)
+			respondError(c, CodeInternal, "Failed to check the Idempotency-Key")
+			return
+		}
+		if !reserved {
+			replayResponse(c, db, record)
+			return
+		}
+
+		// Unless a response is stored, forget the key so that a retry handles the request
+		// again: after a server error, and when the handler panics
+		stored := false
+		defer func() {
+			if !stored {
+				if err := db.DeleteIdempotencyKey(record.UserID, record.Key); err != nil {
+					c.Error(err)
+				}
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		record.Status = recorder.Status()
+		if record.Status >= 500 {
+			return
+		}
+		headers := make(map[string]string)
+		for _, name := range replayedHeaders {
+			if value := recorder.Header().Get(name); value != "" {
+				headers[name] = value
+			}
+		}
+		encoded, err := json.Marshal(headers)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		record.Headers = string(encoded)
+		record.Body = recorder.body.Bytes()
+		if err := db.CompleteIdempotencyKey(record); err != nil {
+			c.Error(err)
+			return
+		}
+		stored = true
+	}
+}
+
+// replayResponse answers a request whose key is already recorded
+func replayResponse(c *gin.Context, db database.Service, request database.IdempotencyKey) {
+	stored, err := db.GetIdempotencyKey(request.UserID, request.Key)
+	if err != nil {
+		// Deleted in the meantime, after the first attempt failed
+		c.Error(err)
+		respondError(c, CodeRequestInProgress, "A request with this Idempotency-Key is being handled, retry later")
+		return
+	}
+
+	switch {
+	case stored.Fingerprint != request.Fingerprint:
+		respondError(c, CodeIdempotencyKeyReused, "The Idempotency-Key was already used for a different request")
+	case stored.Status == 0:
+		respondError(c, CodeRequestInProgress, "A request with this Idempotency-Key is being handled, retry later")
+	default:
+		var headers map[string]string
+		if err := json.Unmarshal([]byte(stored.Headers), &headers); err != nil {
+			c.Error(err)
+		}
+		for name, value := range headers {
+			c.Header(name, value)
+		}
+		c.Header(replayedHeader, "true")
+		c.Status(stored.Status)
+		c.Writer.Write(stored.Body)
+		c.Abort()
+	}
+}
+
+// requestFingerprint identifies a request by its method, path and body
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func isValidIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLen {
+		return false
+	}
+	for _, r := range key {
+		if r < 0x20 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// responseRecorder keeps a copy of the response body as it is written
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
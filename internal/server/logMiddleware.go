@@ -0,0 +1,90 @@
+package server
+
+import (
+	"booker-be/internal/logging"
+	"booker-be/internal/protocol"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	requestIDHeader    = "X-Request-ID"
+	requestIDKey       = "requestID" // Key to store the request ID in Gin context
+	maxRequestIDLength = 64
+)
+
+var probePaths = map[string]bool{"/healthz": true, "/readyz": true, "/metrics": true}
+
+// RequestIDMiddleware gives every request an ID, taken from the X-Request-ID header
+// when a proxy already set a sane one. The ID is returned in the same header and
+// added to every line logged with the request's context.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !isValidRequestID(id) {
+			id = protocol.GenerateID()
+		}
+
+		c.Header(requestIDHeader, id)
+		c.Set(requestIDKey, id)
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), "request_id", id))
+		c.Next()
+	}
+}
+
+// LoggerMiddleware logs every request once it has been handled
+func LoggerMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		} else if probePaths[c.Request.URL.Path] {
+			// Probes arrive every few seconds and would drown out everything else
+			level = slog.LevelDebug
+		}
+
+		attrs := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"bytes", c.Writer.Size(),
+			"client_ip", c.ClientIP(),
+		}
+		if query := c.Request.URL.Query(); len(query) > 0 {
+			attrs = append(attrs, "query", logging.RedactQuery(query))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, "errors", c.Errors.String())
+		}
+		// AuthMiddleware has added the user to the request's context by now
+		slog.Log(c.Request.Context(), level, "Request handled", attrs...)
+	}
+}
+
+// RecoveryMiddleware turns a panicking handler into a 500 response and logs the panic
+func RecoveryMiddleware() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, err any) {
+		slog.ErrorContext(c.Request.Context(), "Panic while handling request", "error", err, "stack", string(debug.Stack()))
+		respondError(c, CodeInternal, "Internal server error")
+	})
+}
+
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
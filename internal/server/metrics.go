@@ -0,0 +1,53 @@
+package server
+
+import (
+	"booker-be/internal/metrics"
+	"crypto/subtle"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	httpRequests = metrics.Default.NewCounter("booker_http_requests_total", "HTTP requests handled, by method, route and status.", "method", "route", "status")
+	httpDuration = metrics.Default.NewHistogram("booker_http_request_duration_seconds", "Time taken to handle HTTP requests, by method and route.", metrics.DefaultBuckets, "method", "route")
+	httpInFlight = metrics.Default.NewGauge("booker_http_requests_in_flight", "HTTP requests currently being handled, including open event streams and WebSockets.")
+)
+
+// MetricsMiddleware records the count and duration of requests by route template,
+// so that IDs in paths don't create a series per resource
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		httpInFlight.Add(1)
+		defer httpInFlight.Add(-1)
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequests.Inc(c.Request.Method, route, strconv.Itoa(c.Writer.Status()))
+		httpDuration.Observe(time.Since(start).Seconds(), c.Request.Method, route)
+	}
+}
+
+// GetMetrics serves the metrics in the Prometheus text format. When a token is
+// configured, scrapers must send it as a bearer token.
+func GetMetrics(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token != "" {
+			expected := authorizationTypeBearer + " " + token
+			if subtle.ConstantTimeCompare([]byte(c.GetHeader(authorizationHeaderKey)), []byte(expected)) != 1 {
+				respondError(c, CodeUnauthorized, "Unauthorized")
+				return
+			}
+		}
+
+		c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		c.Status(200)
+		_ = metrics.Default.Write(c.Writer)
+	}
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"booker-be/internal/database"
+	"booker-be/internal/protocol"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetNotificationPreferences returns which emails the authenticated user receives
+func GetNotificationPreferences(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		prefs, err := db.GetNotificationPreferences(userID.(string))
+		if err != nil {
+			respondError(c, CodeInternal, "Failed to retrieve notification preferences")
+			return
+		}
+		c.JSON(200, newNotificationPreferencesResponse(prefs))
+	}
+}
+
+func UpdateNotificationPreferences(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		var prefs protocol.NotificationPreferencesMessage
+		if !bindJSON(c, &prefs) {
+			return
+		}
+
+		updated := database.NotificationPreferences{
+			UserID:         userID.(string),
+			UpdatedAt:      protocol.GetCurrentTime(),
+			BookingChanges: prefs.BookingChanges,
+			OwnChanges:     prefs.OwnChanges,
+			ArrivalsDigest: prefs.ArrivalsDigest,
+		}
+		if err := db.UpsertNotificationPreferences(updated); err != nil {
+			respondError(c, CodeInternal, "Failed to update notification preferences")
+			return
+		}
+
+		c.JSON(200, newNotificationPreferencesResponse(updated))
+	}
+}
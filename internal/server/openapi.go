@@ -0,0 +1,345 @@
+package server
+
+import (
+	"booker-be/internal/events"
+	"booker-be/internal/jobs"
+	"booker-be/internal/openapi"
+	"booker-be/internal/protocol"
+	"booker-be/internal/touristtax"
+	"booker-be/internal/version"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed docs/index.html
+var docsPage []byte
+
+// routeDoc documents one route of SetupRoutes. Every registered route needs one,
+// which TestEveryRouteIsDocumented checks. A route without one is only logged at
+// startup and left out of the document, so the API is still served.
+type routeDoc struct {
+	summary     string
+	public      bool // No session required
+	query       []queryParam
+	request     any    // JSON request body
+	upload      bool   // multipart/form-data request with a "file" field
+	status      int    // Success status, 200 when zero
+	response    any    // JSON body of the success response
+	contentType string // Success content type when the response isn't JSON
+	ifMatch     bool   // Requires the If-Match header, see checkIfMatch
+	noReplay    bool   // POST route without Idempotency-Key support, see IdempotencyMiddleware
+	location    bool   // The success response has a Location header, the URL of the created resource
+	paginated   bool   // Returns a page at a time, see nextCursorHeader
+}
+
+type queryParam struct {
+	name        string
+	typ         string
+	description string
+}
+
+// Response bodies that handlers build with gin.H
+type (
+	errorResponse struct {
+		Error APIError `json:"error"`
+	}
+	messageResponse struct {
+		Message string `json:"message"`
+	}
+	loginResponse struct {
+		Message  string `json:"message"`
+		Token    string `json:"token"`
+		UserID   string `json:"userID"`
+		Username string `json:"username"`
+	}
+	healthResponse struct {
+		Status string `json:"status"`
+	}
+	readinessResponse struct {
+		Status string                 `json:"status"`
+		Checks map[string]checkResult `json:"checks"`
+	}
+)
+
+var (
+	limitParam        = queryParam{"limit", "integer", "Maximum number of items"}
+	bookingListParams = []queryParam{
+		{"from", "string", "Bookings with a night on or after this date, YYYY-MM-DD"},
+		{"to", "string", "Bookings with a night before this date, YYYY-MM-DD"},
+		{"property_id", "string", "Bookings of this property"},
+		{"guest_name", "string", "Bookings whose guest name contains this text, ignoring case"},
+		{"status", "string", "upcoming, current or past, as of today"},
+		{"sort", "string", "start_date (default) or created_at; a leading - sorts in descending order"},
+	}
+	tokenParam = queryParam{"access_token", "string", "Session token, for clients that can't set the Authorization header"}
+)
+
+var routeDocs = map[string]routeDoc{
+	// Users
+	"POST /users/register": {summary: "Register a user", public: true, request: protocol.CreateUserMessage{}, status: 201, response: protocol.UserResponse{}, location: true},
+	"POST /users/login":    {summary: "Log in and create a session", public: true, request: protocol.LoginUserMessage{}, response: loginResponse{}, noReplay: true},
+	"GET /users/me":        {summary: "Get the current user", response: protocol.UserResponse{}},
+	"PUT /users/me":        {summary: "Update the current user", request: protocol.UpdateUserMessage{}, response: protocol.UserResponse{}},
+
+	// Notifications
+	"GET /notifications/preferences": {summary: "Get the current user's notification preferences", response: protocol.NotificationPreferencesResponse{}},
+	"PUT /notifications/preferences": {summary: "Update the current user's notification preferences", request: protocol.NotificationPreferencesMessage{}, response: protocol.NotificationPreferencesResponse{}},
+
+	// Groups
+	"GET /groups/:id":            {summary: "List the groups of a user", response: []protocol.GroupResponse{}},
+	"GET /groups/group/:groupID": {summary: "Get a group, with its version as ETag", response: protocol.GroupResponse{}},
+	"POST /groups/":              {summary: "Create a group", request: protocol.GroupCreateMessage{}, status: 201, response: protocol.GroupResponse{}, location: true},
+	"POST /groups/join/:code":    {summary: "Join a group with an invitation code", response: protocol.GroupResponse{}},
+	"GET /groups/:id/events": {summary: "Stream the events of a group (Server-Sent Events)", query: []queryParam{tokenParam,
+		{"last_event_id", "integer", "Resume after this event, like the Last-Event-ID header"}}, response: events.Event{}, contentType: "text/event-stream"},
+	"GET /groups/:id/ws": {summary: "Open the collaboration WebSocket of a group", query: []queryParam{tokenParam}, status: 101},
+	"GET /groups/:id/search": {summary: "Search the bookings, guests and properties of a group", query: []queryParam{
+		{"q", "string", "Words to find in guest names, notes and property names"},
+		{"limit", "integer", "Maximum number of matches of each type"},
+	}, response: protocol.SearchResponse{}},
+	"POST /group-codes/": {summary: "Create an invitation code for a group", request: protocol.GroupCodeMessage{}, status: 201, response: protocol.GroupCodeResponse{}},
+
+	// Properties
+	"GET /properties/group/:groupID":  {summary: "List the properties of a group", response: []protocol.PropertyResponse{}},
+	"POST /properties/group/:groupID": {summary: "Create a property", request: protocol.CreatePropertyMessage{}, status: 201, response: protocol.PropertyResponse{}, location: true},
+	"GET /properties/:propertyID":     {summary: "Get a property, with its version as ETag", response: protocol.PropertyResponse{}},
+	"PUT /properties/:propertyID":     {summary: "Update a property", request: protocol.UpdatePropertyMessage{}, response: protocol.PropertyResponse{}, ifMatch: true},
+	"DELETE /properties/:propertyID":  {summary: "Delete a property with its bookings", response: messageResponse{}, ifMatch: true},
+
+	// Bookings
+	"GET /bookings/property/:propertyID":              {summary: "List the bookings of a property", query: bookingListParams, response: []protocol.BookingResponse{}, paginated: true},
+	"GET /bookings/group/:groupID":                    {summary: "List the bookings of a group", query: bookingListParams, response: []protocol.BookingResponse{}, paginated: true},
+	"POST /bookings/property/:propertyID":             {summary: "Create a booking", request: protocol.CreateBookingMessage{}, status: 201, response: protocol.BookingResponse{}, location: true},
+	"GET /bookings/:bookingID":                        {summary: "Get a booking, with its version as ETag", response: protocol.BookingResponse{}},
+	"PUT /bookings/:bookingID":                        {summary: "Replace the fields of a booking", request: protocol.UpdateBookingMessage{}, response: protocol.BookingResponse{}, ifMatch: true},
+	"PATCH /bookings/:bookingID":                      {summary: "Change some fields of a booking (JSON merge patch)", request: protocol.PatchBookingMessage{}, response: protocol.BookingResponse{}, ifMatch: true},
+	"DELETE /bookings/:bookingID":                     {summary: "Delete a booking", response: messageResponse{}, ifMatch: true},
+	"GET /bookings/:bookingID/registrations":          {summary: "List the guest registrations of a booking", response: []protocol.GuestRegistrationResponse{}},
+	"POST /bookings/:bookingID/registrations":         {summary: "Register a guest of a booking", request: protocol.GuestRegistrationMessage{}, status: 201, response: protocol.GuestRegistrationResponse{}, location: true},
+	"GET /bookings/:bookingID/comments":               {summary: "List the comments of a booking as threads", response: []protocol.CommentThreadResponse{}},
+	"POST /bookings/:bookingID/comments":              {summary: "Comment on a booking", request: protocol.CreateCommentMessage{}, status: 201, response: protocol.CommentResponse{}, location: true},
+	"GET /bookings/:bookingID/comments/:commentID":    {summary: "Get a comment of a booking", response: protocol.CommentResponse{}},
+	"PUT /bookings/:bookingID/comments/:commentID":    {summary: "Edit a comment", request: protocol.UpdateCommentMessage{}, response: protocol.CommentResponse{}},
+	"DELETE /bookings/:bookingID/comments/:commentID": {summary: "Delete a comment", response: messageResponse{}},
+
+	// Activity
+	"GET /activity/group/:groupID": {summary: "List the recent activity of a group, newest first", query: []queryParam{limitParam}, response: []protocol.ActivityResponse{}},
+
+	// Guest registrations
+	"GET /registrations/group/:groupID/export": {summary: "Export the guest registrations of a group as CSV or XML", query: []queryParam{
+		{"format", "string", "csv (default) or xml"},
+		{"status", "string", "reported or unreported"},
+		{"from", "string", "First arrival date, YYYY-MM-DD"},
+		{"to", "string", "Arrival date to stop before, YYYY-MM-DD"},
+	}, contentType: "text/csv"},
+	"POST /registrations/group/:groupID/reported": {summary: "Mark guest registrations as reported or not", request: protocol.GuestRegistrationStatusMessage{}, response: []protocol.GuestRegistrationResponse{}},
+	"GET /registrations/:registrationID":          {summary: "Get a guest registration", response: protocol.GuestRegistrationResponse{}},
+	"PUT /registrations/:registrationID":          {summary: "Update a guest registration", request: protocol.GuestRegistrationMessage{}, response: protocol.GuestRegistrationResponse{}},
+	"DELETE /registrations/:registrationID":       {summary: "Delete a guest registration", response: messageResponse{}},
+
+	// Attachments
+	"GET /attachments/booking/:bookingID":    {summary: "List the attachments of a booking", response: []protocol.AttachmentResponse{}},
+	"POST /attachments/booking/:bookingID":   {summary: "Upload an attachment to a booking", upload: true, status: 201, response: protocol.AttachmentResponse{}, location: true},
+	"GET /attachments/property/:propertyID":  {summary: "List the attachments of a property", response: []protocol.AttachmentResponse{}},
+	"POST /attachments/property/:propertyID": {summary: "Upload an attachment to a property", upload: true, status: 201, response: protocol.AttachmentResponse{}, location: true},
+	"GET /attachments/:attachmentID":         {summary: "Download an attachment", contentType: "application/octet-stream"},
+	"DELETE /attachments/:attachmentID":      {summary: "Delete an attachment", response: messageResponse{}},
+
+	// Guests
+	"GET /guests/group/:groupID":        {summary: "List the guests of a group", response: []protocol.GuestResponse{}},
+	"GET /guests/group/:groupID/search": {summary: "Search the guests of a group", query: []queryParam{{"q", "string", "Text to search for in names, emails and phone numbers"}}, response: []protocol.GuestResponse{}},
+	"POST /guests/group/:groupID":       {summary: "Create a guest", request: protocol.GuestMessage{}, status: 201, response: protocol.GuestResponse{}, location: true},
+	"GET /guests/:guestID":              {summary: "Get a guest with their stays", response: protocol.GuestDetailResponse{}},
+	"PUT /guests/:guestID":              {summary: "Update a guest", request: protocol.GuestMessage{}, response: protocol.GuestResponse{}},
+	"DELETE /guests/:guestID":           {summary: "Delete a guest", response: messageResponse{}},
+
+	// Tourist tax
+	"GET /tourist-tax/group/:groupID":        {summary: "List the tourist tax rules of a group", response: []protocol.TouristTaxRuleResponse{}},
+	"POST /tourist-tax/group/:groupID":       {summary: "Create a tourist tax rule", request: protocol.TouristTaxRuleMessage{}, status: 201, response: protocol.TouristTaxRuleResponse{}, location: true},
+	"GET /tourist-tax/group/:groupID/report": {summary: "Report the tourist tax of a group for a month", query: []queryParam{{"month", "string", "YYYY-MM"}}, response: touristtax.Report{}},
+	"GET /tourist-tax/booking/:bookingID":    {summary: "Compute the tourist tax of a booking", response: touristtax.Assessment{}},
+	"GET /tourist-tax/:ruleID":               {summary: "Get a tourist tax rule", response: protocol.TouristTaxRuleResponse{}},
+	"PUT /tourist-tax/:ruleID":               {summary: "Update a tourist tax rule", request: protocol.TouristTaxRuleMessage{}, response: protocol.TouristTaxRuleResponse{}},
+	"DELETE /tourist-tax/:ruleID":            {summary: "Delete a tourist tax rule", response: messageResponse{}},
+
+	// Webhooks
+	"GET /webhooks/group/:groupID":        {summary: "List the webhooks of a group", response: []protocol.WebhookResponse{}},
+	"POST /webhooks/group/:groupID":       {summary: "Subscribe a URL to booking events; the signing secret is only returned here", request: protocol.WebhookMessage{}, status: 201, response: protocol.WebhookResponse{}, location: true},
+	"GET /webhooks/:webhookID":            {summary: "Get a webhook, without its secret", response: protocol.WebhookResponse{}},
+	"PUT /webhooks/:webhookID":            {summary: "Update a webhook", request: protocol.WebhookMessage{}, response: protocol.WebhookResponse{}},
+	"DELETE /webhooks/:webhookID":         {summary: "Delete a webhook", response: messageResponse{}},
+	"GET /webhooks/:webhookID/deliveries": {summary: "List the deliveries of a webhook, newest first", query: []queryParam{limitParam}, response: []protocol.WebhookDeliveryResponse{}},
+
+	// Administration
+	"GET /admin/jobs":            {summary: "List the background jobs", response: []jobs.Status{}},
+	"POST /admin/jobs/:name/run": {summary: "Run a background job now", status: 202, response: messageResponse{}},
+
+	// Operations
+	"GET /healthz":      {summary: "Check that the process is alive", public: true, response: healthResponse{}},
+	"GET /readyz":       {summary: "Check that the server can handle traffic", public: true, response: readinessResponse{}},
+	"GET /version":      {summary: "Get the version of the running build", public: true, response: version.Info{}},
+	"GET /metrics":      {summary: "Prometheus metrics, protected by the metrics token when one is configured", public: true, contentType: "text/plain"},
+	"GET /openapi.json": {summary: "This document", public: true, contentType: "application/json"},
+	"GET /docs":         {summary: "API documentation", public: true, contentType: "text/html"},
+}
+
+// GetOpenAPI serves the OpenAPI document, which is built once all routes are registered
+func GetOpenAPI(spec *[]byte) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Data(200, "application/json; charset=utf-8", *spec)
+	}
+}
+
+// GetDocs serves a page rendering the OpenAPI document
+func GetDocs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Data(200, "text/html; charset=utf-8", docsPage)
+	}
+}
+
+// buildOpenAPI documents the registered routes. When a route has no entry in
+// routeDocs, or an entry has no route, it returns an error along with the
+// document of the routes it could describe.
+func buildOpenAPI(routes gin.RoutesInfo) ([]byte, error) {
+	b := openapi.NewBuilder("Booker API", version.Version)
+	b.AddSecurityScheme("bearerAuth", openapi.SecurityScheme{Type: "http", Scheme: "bearer"})
+	errorSchema := b.SchemaFor(errorResponse{})
+
+	var undocumented []string
+	registered := make(map[string]bool)
+	for _, r := range routes {
+		key := r.Method + " " + r.Path
+		registered[key] = true
+		doc, ok := routeDocs[key]
+		if !ok {
+			undocumented = append(undocumented, key)
+			continue
+		}
+
+		path, params := openAPIPath(r.Path)
+		tag := strings.SplitN(strings.TrimPrefix(r.Path, "/"), "/", 2)[0]
+		op := openapi.Operation{
+			Tags:        []string{tag},
+			Summary:     doc.summary,
+			OperationID: operationID(r.Method, r.Path),
+			Security:    []map[string][]string{},
+			Responses: map[string]openapi.Response{
+				"default": {Description: "Error", Content: map[string]openapi.MediaType{"application/json": {Schema: errorSchema}}},
+			},
+		}
+		if !doc.public {
+			op.Security = append(op.Security, map[string][]string{"bearerAuth": {}})
+		}
+
+		for _, p := range params {
+			op.Parameters = append(op.Parameters, openapi.Parameter{Name: p, In: "path", Required: true, Schema: &openapi.Schema{Type: "string"}})
+		}
+		query := doc.query
+		if doc.paginated {
+			query = append(slices.Clip(query),
+				queryParam{"limit", "integer", "Page size. Without limit and cursor, every item is returned."},
+				queryParam{"cursor", "string", "The " + nextCursorHeader + " header of the previous page"})
+		}
+		for _, q := range query {
+			op.Parameters = append(op.Parameters, openapi.Parameter{Name: q.name, In: "query", Description: q.description, Schema: &openapi.Schema{Type: q.typ}})
+		}
+		if doc.ifMatch {
+			op.Parameters = append(op.Parameters, openapi.Parameter{Name: "If-Match", In: "header", Required: true,
+				Description: "ETag of the version to change. 412 with the current state when it has changed since.", Schema: &openapi.Schema{Type: "string"}})
+		}
+		if r.Method == "POST" && !doc.noReplay {
+			op.Parameters = append(op.Parameters, openapi.Parameter{Name: "Idempotency-Key", In: "header",
+				Description: "Unique key of the request. Retries with the same key get the original response back.", Schema: &openapi.Schema{Type: "string"}})
+		}
+
+		switch {
+		case doc.upload:
+			op.RequestBody = &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+				"multipart/form-data": {Schema: &openapi.Schema{Type: "object", Properties: map[string]*openapi.Schema{
+					"file": {Type: "string", Format: "binary"},
+				}}},
+			}}
+		case doc.request != nil:
+			op.RequestBody = &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+				"application/json": {Schema: b.SchemaFor(doc.request)},
+			}}
+		}
+
+		status := doc.status
+		if status == 0 {
+			status = 200
+		}
+		success := openapi.Response{Description: "Success"}
+		switch {
+		case doc.contentType != "":
+			var schema *openapi.Schema
+			if doc.response != nil {
+				schema = b.SchemaFor(doc.response)
+			}
+			success.Content = map[string]openapi.MediaType{doc.contentType: {Schema: schema}}
+		case doc.response != nil:
+			success.Content = map[string]openapi.MediaType{"application/json": {Schema: b.SchemaFor(doc.response)}}
+		}
+		if doc.location {
+			success.Headers = map[string]openapi.Header{
+				"Location": {Description: "URL of the created resource", Schema: &openapi.Schema{Type: "string"}},
+			}
+		}
+		if doc.paginated {
+			success.Headers = map[string]openapi.Header{
+				nextCursorHeader: {Description: "Cursor of the next page, absent on the last page", Schema: &openapi.Schema{Type: "string"}},
+			}
+		}
+		op.Responses[strconv.Itoa(status)] = success
+
+		b.Add(r.Method, path, op)
+	}
+
+	var stale []string
+	for key := range routeDocs {
+		if !registered[key] {
+			stale = append(stale, key)
+		}
+	}
+	spec, err := json.Marshal(b.Document())
+	if err != nil {
+		return nil, err
+	}
+	if len(undocumented) > 0 || len(stale) > 0 {
+		slices.Sort(undocumented)
+		slices.Sort(stale)
+		return spec, fmt.Errorf("OpenAPI documentation is out of date: routes without routeDocs entry %v, routeDocs entries without route %v", undocumented, stale)
+	}
+	return spec, nil
+}
+
+// openAPIPath turns /bookings/:bookingID into /bookings/{bookingID} and returns the parameter names
+func openAPIPath(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	var params []string
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			params = append(params, s[1:])
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operationID names an operation after its method and path, e.g. get_bookings_bookingID_comments
+func operationID(method, path string) string {
+	var parts []string
+	for _, s := range strings.Split(path, "/") {
+		s = strings.TrimLeft(s, ":*")
+		if s != "" {
+			parts = append(parts, strings.ReplaceAll(s, "-", "_"))
+		}
+	}
+	return strings.ToLower(method) + "_" + strings.Join(parts, "_")
+}
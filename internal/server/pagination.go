@@ -0,0 +1,36 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// Listings that grow without bound are returned a page at a time. When there are
+// more items, the X-Next-Cursor header holds a cursor; passing it back as the cursor
+// query parameter, with the same filters and order, returns the next page.
+const nextCursorHeader = "X-Next-Cursor"
+
+// pageCursor is the position of the last item of a page. Clients treat it as opaque.
+type pageCursor struct {
+	Sort string `json:"s"` // The order the position is in
+	Key  string `json:"k"` // Value of the last item in that order
+	ID   string `json:"i"` // ID of the last item, for items with the same value
+}
+
+func encodeCursor(cursor pageCursor) string {
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor reads a cursor made by encodeCursor for a listing in the given order
+func decodeCursor(s, sort string) (pageCursor, bool) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return pageCursor{}, false
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(b, &cursor); err != nil || cursor.Sort != sort || cursor.ID == "" {
+		return pageCursor{}, false
+	}
+	return cursor, true
+}
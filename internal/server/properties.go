@@ -2,7 +2,10 @@ package server
 
 import (
 	"booker-be/internal/database"
+	"booker-be/internal/events"
 	"booker-be/internal/protocol"
+	"booker-be/internal/storage"
+	"errors"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,7 +14,7 @@ func GetPropertiesByGroupID(db database.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		if !exists {
-			c.JSON(401, gin.H{"error": "Unauthorized"})
+			respondError(c, CodeUnauthorized, "Unauthorized")
 			return
 		}
 
@@ -19,43 +22,67 @@ func GetPropertiesByGroupID(db database.Service) gin.HandlerFunc {
 
 		// Check if user belongs to this group
 		if !db.UserBelongsToGroup(userID.(string), groupID) {
-			c.JSON(403, gin.H{"error": "Forbidden: You don't have access to this group"})
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this group")
 			return
 		}
 
 		properties, err := db.GetPropertiesByGroupID(groupID)
 		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to retrieve properties"})
+			respondError(c, CodeInternal, "Failed to retrieve properties")
 			return
 		}
-		c.JSON(200, properties)
+		c.JSON(200, mapAll(properties, newPropertyResponse))
 	}
 }
 
-func CreateProperty(db database.Service) gin.HandlerFunc {
+// GetProperty returns a property with its version as ETag, for a later If-Match
+func GetProperty(db database.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		if !exists {
-			c.JSON(401, gin.H{"error": "Unauthorized"})
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		property, err := db.GetPropertyByID(c.Param("propertyID"))
+		if err != nil {
+			respondError(c, CodeNotFound, "Property not found")
+			return
+		}
+
+		// Check if user belongs to this property's group
+		if !db.UserBelongsToGroup(userID.(string), property.GroupID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this property")
+			return
+		}
+
+		respondWithVersion(c, property.Version, newPropertyResponse(property))
+	}
+}
+
+func CreateProperty(db database.Service, hub *events.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
 			return
 		}
 
 		var property protocol.CreatePropertyMessage
-		if err := c.ShouldBindJSON(&property); err != nil {
-			c.JSON(400, gin.H{"error": "Invalid input"})
+		if !bindJSON(c, &property) {
 			return
 		}
 
 		// Get the groupID from the URL parameter
 		groupID := c.Param("groupID")
 		if property.GroupID != groupID {
-			c.JSON(403, gin.H{"error": "You are not allowed to create properties for this owner"})
+			respondError(c, CodeForbidden, "You are not allowed to create properties for this owner")
 			return
 		}
 
 		// Check if user belongs to this group
 		if !db.UserBelongsToGroup(userID.(string), groupID) {
-			c.JSON(403, gin.H{"error": "Forbidden: You don't have access to this group"})
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this group")
 			return
 		}
 
@@ -64,22 +91,26 @@ func CreateProperty(db database.Service) gin.HandlerFunc {
 			Name:      property.Name,
 			GroupID:   groupID,
 			CreatedAt: protocol.GetCurrentTime(),
+			Version:   1,
 		}
 
 		err := db.InsertProperty(p)
 		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to create property"})
+			respondError(c, CodeInternal, "Failed to create property")
 			return
 		}
-		c.JSON(201, gin.H{"message": "Property created successfully"})
+
+		publish(hub, groupID, userID.(string), events.PropertyCreated, newPropertyResponse(p))
+		c.Header("ETag", etag(p.Version))
+		respondCreated(c, "/properties/"+p.ID, newPropertyResponse(p))
 	}
 }
 
-func UpdateProperty(db database.Service) gin.HandlerFunc {
+func UpdateProperty(db database.Service, hub *events.Hub) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		if !exists {
-			c.JSON(401, gin.H{"error": "Unauthorized"})
+			respondError(c, CodeUnauthorized, "Unauthorized")
 			return
 		}
 
@@ -88,48 +119,102 @@ func UpdateProperty(db database.Service) gin.HandlerFunc {
 		// Get the property to check group ownership
 		property, err := db.GetPropertyByID(propertyID)
 		if err != nil {
-			c.JSON(404, gin.H{"error": "Property not found"})
+			respondError(c, CodeNotFound, "Property not found")
 			return
 		}
 
 		// Check if user belongs to this property's group
 		if !db.UserBelongsToGroup(userID.(string), property.GroupID) {
-			c.JSON(403, gin.H{"error": "Forbidden: You don't have access to this property"})
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this property")
 			return
 		}
 
-		var updateMsg protocol.UpdatePropertyMessage
-		if err := c.ShouldBindJSON(&updateMsg); err != nil {
-			c.JSON(400, gin.H{"error": "Invalid input"})
+		if !checkIfMatch(c, property.Version, newPropertyResponse(property)) {
 			return
 		}
 
-		// Validate color format if provided (must be hex color or empty)
-		if updateMsg.Color != "" && !isValidHexColor(updateMsg.Color) {
-			c.JSON(400, gin.H{"error": "Invalid color format. Must be hex color (e.g., #FF5733)"})
+		var updateMsg protocol.UpdatePropertyMessage
+		if !bindJSON(c, &updateMsg) {
 			return
 		}
 
 		// Update color
-		if err := db.UpdatePropertyColor(propertyID, updateMsg.Color); err != nil {
-			c.JSON(500, gin.H{"error": "Failed to update property"})
+		err = db.UpdatePropertyColor(propertyID, updateMsg.Color, property.Version)
+		if errors.Is(err, database.ErrVersionConflict) {
+			respondPropertyConflict(c, db, propertyID)
 			return
 		}
+		if err != nil {
+			respondError(c, CodeInternal, "Failed to update property")
+			return
+		}
+
+		updated := property
+		updated.Color = updateMsg.Color
+		updated.Version++
+		publishUpdate(hub, property.GroupID, userID.(string), events.PropertyUpdated, newPropertyResponse(property), newPropertyResponse(updated))
 
-		c.JSON(200, gin.H{"message": "Property updated successfully"})
+		c.Header("ETag", etag(updated.Version))
+		c.JSON(200, newPropertyResponse(updated))
 	}
 }
 
-func isValidHexColor(color string) bool {
-	// Check if it matches hex color format: #RRGGBB
-	if len(color) != 7 || color[0] != '#' {
-		return false
-	}
-	for i := 1; i < 7; i++ {
-		c := color[i]
-		if !((c >= '0' && c <= '9') || (c >= 'A' && c <= 'F') || (c >= 'a' && c <= 'f')) {
-			return false
+// DeleteProperty removes a property together with its bookings and all of their attachments
+func DeleteProperty(db database.Service, blobs storage.BlobStore, hub *events.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
 		}
+
+		propertyID := c.Param("propertyID")
+
+		// Get the property to check group ownership
+		property, err := db.GetPropertyByID(propertyID)
+		if err != nil {
+			respondError(c, CodeNotFound, "Property not found")
+			return
+		}
+
+		// Check if user belongs to this property's group
+		if !db.UserBelongsToGroup(userID.(string), property.GroupID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this property")
+			return
+		}
+
+		if !checkIfMatch(c, property.Version, newPropertyResponse(property)) {
+			return
+		}
+
+		bookings, attachments, err := db.DeletePropertyByID(propertyID, property.Version)
+		if errors.Is(err, database.ErrVersionConflict) {
+			respondPropertyConflict(c, db, propertyID)
+			return
+		}
+		if err != nil {
+			respondError(c, CodeInternal, "Failed to delete property")
+			return
+		}
+		removeBlobs(blobs, attachments)
+
+		for _, b := range bookings {
+			publish(hub, property.GroupID, userID.(string), events.BookingDeleted, newBookingResponse(b))
+		}
+
+		recordActivity(db, property.GroupID, userID.(string), "property.deleted", "property", propertyID, property.Name)
+		publish(hub, property.GroupID, userID.(string), events.PropertyDeleted, newPropertyResponse(property))
+
+		c.JSON(200, gin.H{"message": "Property deleted successfully"})
+	}
+}
+
+// respondPropertyConflict sends the property as it is now, after a change based on an older version failed
+func respondPropertyConflict(c *gin.Context, db database.Service, propertyID string) {
+	current, err := db.GetPropertyByID(propertyID)
+	if err != nil {
+		respondError(c, CodeNotFound, "Property not found")
+		return
 	}
-	return true
+	respondVersionConflict(c, current.Version, newPropertyResponse(current))
 }
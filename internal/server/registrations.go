@@ -0,0 +1,349 @@
+package server
+
+import (
+	"booker-be/internal/database"
+	"booker-be/internal/protocol"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetGuestRegistrationsByBookingID(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		bookingID := c.Param("bookingID")
+
+		// Check if user can access this booking
+		if !db.UserCanAccessBooking(userID.(string), bookingID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this booking")
+			return
+		}
+
+		registrations, err := db.GetGuestRegistrationsByBookingID(bookingID)
+		if err != nil {
+			respondError(c, CodeInternal, "Failed to retrieve guest registrations")
+			return
+		}
+		c.JSON(200, mapAll(registrations, newGuestRegistrationResponse))
+	}
+}
+
+func CreateGuestRegistration(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		var registration protocol.GuestRegistrationMessage
+		if !bindJSON(c, &registration) {
+			return
+		}
+
+		bookingID := c.Param("bookingID")
+
+		// Check if user can access this booking
+		if !db.UserCanAccessBooking(userID.(string), bookingID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this booking")
+			return
+		}
+
+		r := database.GuestRegistration{
+			ID:             protocol.GenerateID(),
+			CreatedAt:      protocol.GetCurrentTime(),
+			BookingID:      bookingID,
+			FirstName:      registration.FirstName,
+			LastName:       registration.LastName,
+			BirthDate:      registration.BirthDate,
+			Nationality:    registration.Nationality,
+			DocumentType:   registration.DocumentType,
+			DocumentNumber: registration.DocumentNumber,
+		}
+
+		if err := db.InsertGuestRegistration(r); err != nil {
+			respondError(c, CodeInternal, "Failed to create guest registration")
+			return
+		}
+		respondCreated(c, "/registrations/"+r.ID, newGuestRegistrationResponse(r))
+	}
+}
+
+func GetGuestRegistration(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		registrationID := c.Param("registrationID")
+
+		// Check if user can access this registration
+		if !db.UserCanAccessGuestRegistration(userID.(string), registrationID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this guest registration")
+			return
+		}
+
+		registration, err := db.GetGuestRegistrationByID(registrationID)
+		if err != nil {
+			respondError(c, CodeNotFound, "Guest registration not found")
+			return
+		}
+		c.JSON(200, newGuestRegistrationResponse(registration))
+	}
+}
+
+func UpdateGuestRegistration(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		var registration protocol.GuestRegistrationMessage
+		if !bindJSON(c, &registration) {
+			return
+		}
+
+		registrationID := c.Param("registrationID")
+
+		// Check if user can access this registration
+		if !db.UserCanAccessGuestRegistration(userID.(string), registrationID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this guest registration")
+			return
+		}
+
+		r := database.GuestRegistration{
+			ID:             registrationID,
+			FirstName:      registration.FirstName,
+			LastName:       registration.LastName,
+			BirthDate:      registration.BirthDate,
+			Nationality:    registration.Nationality,
+			DocumentType:   registration.DocumentType,
+			DocumentNumber: registration.DocumentNumber,
+		}
+
+		if err := db.UpdateGuestRegistration(r); err != nil {
+			respondError(c, CodeInternal, "Failed to update guest registration")
+			return
+		}
+
+		updated, err := db.GetGuestRegistrationByID(registrationID)
+		if err != nil {
+			respondError(c, CodeNotFound, "Guest registration not found")
+			return
+		}
+		c.JSON(200, newGuestRegistrationResponse(updated))
+	}
+}
+
+func DeleteGuestRegistration(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		registrationID := c.Param("registrationID")
+
+		// Check if user can access this registration
+		if !db.UserCanAccessGuestRegistration(userID.(string), registrationID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this guest registration")
+			return
+		}
+
+		if err := db.DeleteGuestRegistration(registrationID); err != nil {
+			respondError(c, CodeInternal, "Failed to delete guest registration")
+			return
+		}
+		c.JSON(200, gin.H{"message": "Guest registration deleted successfully"})
+	}
+}
+
+// SetGuestRegistrationsReported flags or unflags registrations of a group as reported to the authorities
+func SetGuestRegistrationsReported(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		var status protocol.GuestRegistrationStatusMessage
+		if !bindJSON(c, &status) {
+			return
+		}
+
+		groupID := c.Param("groupID")
+
+		// Check if user belongs to this group
+		if !db.UserBelongsToGroup(userID.(string), groupID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this group")
+			return
+		}
+
+		// Check that every registration is for a stay of this group
+		for _, id := range status.IDs {
+			if !db.GuestRegistrationInGroup(id, groupID) {
+				abortWithError(c, invalidField("ids", fieldInvalidValue, "Guest registration "+id+" does not belong to this group"))
+				return
+			}
+		}
+
+		if err := db.SetGuestRegistrationsReported(status.IDs, status.Reported, time.Now().Format(time.RFC3339)); err != nil {
+			respondError(c, CodeInternal, "Failed to update guest registrations")
+			return
+		}
+
+		updated := make([]protocol.GuestRegistrationResponse, 0, len(status.IDs))
+		for _, id := range status.IDs {
+			r, err := db.GetGuestRegistrationByID(id)
+			if err != nil {
+				respondError(c, CodeInternal, "Failed to retrieve guest registrations")
+				return
+			}
+			updated = append(updated, newGuestRegistrationResponse(r))
+		}
+		c.JSON(200, updated)
+	}
+}
+
+// registrationExportRow is one guest in the layout uploaded to the authorities' portal
+type registrationExportRow struct {
+	XMLName        xml.Name `xml:"Guest"`
+	RegistrationID string   `xml:"RegistrationId"`
+	BookingID      string   `xml:"BookingId"`
+	Property       string   `xml:"Property"`
+	ArrivalDate    string   `xml:"ArrivalDate"`
+	DepartureDate  string   `xml:"DepartureDate"`
+	FirstName      string   `xml:"FirstName"`
+	LastName       string   `xml:"LastName"`
+	BirthDate      string   `xml:"BirthDate"`
+	Nationality    string   `xml:"Nationality"`
+	DocumentType   string   `xml:"DocumentType"`
+	DocumentNumber string   `xml:"DocumentNumber"`
+	Reported       bool     `xml:"Reported"`
+}
+
+type registrationExport struct {
+	XMLName xml.Name                `xml:"GuestRegistrations"`
+	GroupID string                  `xml:"groupId,attr"`
+	From    string                  `xml:"from,attr,omitempty"`
+	To      string                  `xml:"to,attr,omitempty"`
+	Guests  []registrationExportRow `xml:"Guest"`
+}
+
+// ExportGuestRegistrations exports the guest registrations of a group's stays arriving between
+// ?from= and ?to= (YYYY-MM-DD, to exclusive) as CSV (default) or XML (?format=xml).
+// ?status=unreported|reported limits the export to registrations with that flag.
+func ExportGuestRegistrations(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		groupID := c.Param("groupID")
+
+		// Check if user belongs to this group
+		if !db.UserBelongsToGroup(userID.(string), groupID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this group")
+			return
+		}
+
+		format := c.DefaultQuery("format", "csv")
+		if format != "csv" && format != "xml" {
+			abortWithError(c, invalidField("format", fieldInvalidValue, "Format must be csv or xml"))
+			return
+		}
+
+		status := c.Query("status")
+		if status != "" && status != "reported" && status != "unreported" {
+			abortWithError(c, invalidField("status", fieldInvalidValue, "Status must be reported or unreported"))
+			return
+		}
+
+		from, to := c.Query("from"), c.Query("to")
+		if from != "" && !protocol.IsValidDate(from) {
+			abortWithError(c, invalidField("from", fieldInvalidFormat, "Invalid date format"))
+			return
+		}
+		if to != "" && !protocol.IsValidDate(to) {
+			abortWithError(c, invalidField("to", fieldInvalidFormat, "Invalid date format"))
+			return
+		}
+
+		filter := database.GuestRegistrationFilter{GroupID: groupID, From: from, To: to}
+		if status != "" {
+			reported := status == "reported"
+			filter.Reported = &reported
+		}
+		registrations, err := db.ExportGuestRegistrations(filter)
+		if err != nil {
+			respondError(c, CodeInternal, "Failed to retrieve guest registrations")
+			return
+		}
+
+		export := registrationExport{GroupID: groupID, From: from, To: to, Guests: []registrationExportRow{}}
+		for _, r := range registrations {
+			export.Guests = append(export.Guests, registrationExportRow{
+				RegistrationID: r.ID,
+				BookingID:      r.BookingID,
+				Property:       r.PropertyName,
+				ArrivalDate:    r.ArrivalDate,
+				DepartureDate:  r.DepartureDate,
+				FirstName:      r.FirstName,
+				LastName:       r.LastName,
+				BirthDate:      r.BirthDate,
+				Nationality:    r.Nationality,
+				DocumentType:   r.DocumentType,
+				DocumentNumber: r.DocumentNumber,
+				Reported:       r.Reported,
+			})
+		}
+
+		filename := "guest-registrations-" + time.Now().Format("2006-01-02") + "." + format
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+		if format == "xml" {
+			out, err := xml.MarshalIndent(export, "", "  ")
+			if err != nil {
+				respondError(c, CodeInternal, "Failed to export guest registrations")
+				return
+			}
+			c.Data(200, "application/xml; charset=utf-8", append([]byte(xml.Header), out...))
+			return
+		}
+
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		_ = w.Write([]string{"registration_id", "booking_id", "property", "arrival_date", "departure_date",
+			"first_name", "last_name", "birth_date", "nationality", "document_type", "document_number", "reported"})
+		for _, g := range export.Guests {
+			reported := "no"
+			if g.Reported {
+				reported = "yes"
+			}
+			_ = w.Write([]string{g.RegistrationID, g.BookingID, g.Property, g.ArrivalDate, g.DepartureDate,
+				g.FirstName, g.LastName, g.BirthDate, g.Nationality, g.DocumentType, g.DocumentNumber, reported})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			respondError(c, CodeInternal, "Failed to export guest registrations")
+			return
+		}
+		c.Data(200, "text/csv; charset=utf-8", buf.Bytes())
+	}
+}
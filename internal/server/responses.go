@@ -0,0 +1,207 @@
+package server
+
+import (
+	"booker-be/internal/database"
+	"booker-be/internal/protocol"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handlers respond with the protocol response messages, never with database models.
+// Creating a resource responds 201 with the resource and, when it has a URL of its
+// own, a Location header; changing one responds with the resource as it is now.
+
+// respondCreated sends a new resource along with its URL
+func respondCreated(c *gin.Context, location string, resource any) {
+	c.Header("Location", location)
+	c.JSON(201, resource)
+}
+
+// mapAll converts a list of models, into an empty list rather than null when there are none
+func mapAll[T, R any](items []T, convert func(T) R) []R {
+	results := make([]R, 0, len(items))
+	for _, item := range items {
+		results = append(results, convert(item))
+	}
+	return results
+}
+
+func newUserResponse(u database.User) protocol.UserResponse {
+	return protocol.UserResponse{
+		ID:       u.ID,
+		Username: u.Username,
+		Email:    u.Email,
+	}
+}
+
+func newNotificationPreferencesResponse(p database.NotificationPreferences) protocol.NotificationPreferencesResponse {
+	return protocol.NotificationPreferencesResponse{
+		UserID:         p.UserID,
+		UpdatedAt:      p.UpdatedAt,
+		BookingChanges: p.BookingChanges,
+		OwnChanges:     p.OwnChanges,
+		ArrivalsDigest: p.ArrivalsDigest,
+	}
+}
+
+func newGroupResponse(g database.Group) protocol.GroupResponse {
+	return protocol.GroupResponse{
+		ID:        g.ID,
+		CreatedAt: g.CreatedAt,
+		Name:      g.Name,
+		OwnerID:   g.OwnerID,
+		Version:   g.Version,
+	}
+}
+
+func newGroupCodeResponse(gc database.GroupCode) protocol.GroupCodeResponse {
+	return protocol.GroupCodeResponse{
+		GroupID:  gc.GroupID,
+		Code:     gc.Code,
+		ActiveTo: gc.ActiveTo,
+	}
+}
+
+func newPropertyResponse(p database.Property) protocol.PropertyResponse {
+	return protocol.PropertyResponse{
+		ID:        p.ID,
+		CreatedAt: p.CreatedAt,
+		GroupID:   p.GroupID,
+		Name:      p.Name,
+		Color:     p.Color,
+		Version:   p.Version,
+	}
+}
+
+func newBookingResponse(b database.Booking) protocol.BookingResponse {
+	return protocol.BookingResponse{
+		ID:         b.ID,
+		CreatedAt:  b.CreatedAt,
+		CreatedBy:  b.CreatedBy,
+		PropertyID: b.PropertyID,
+		StartDate:  b.StartDate,
+		EndDate:    b.EndDate,
+		GuestName:  b.GuestName,
+		Adults:     b.Adults,
+		Children:   b.Children,
+		GuestID:    b.GuestID,
+		Notes:      b.Notes,
+		Version:    b.Version,
+	}
+}
+
+func newCommentResponse(bc database.BookingComment) protocol.CommentResponse {
+	return protocol.CommentResponse{
+		ID:         bc.ID,
+		CreatedAt:  bc.CreatedAt,
+		UpdatedAt:  bc.UpdatedAt,
+		BookingID:  bc.BookingID,
+		ParentID:   bc.ParentID,
+		AuthorID:   bc.AuthorID,
+		AuthorName: bc.AuthorName,
+		Body:       bc.Body,
+		Deleted:    bc.Deleted,
+	}
+}
+
+func newGuestResponse(g database.Guest) protocol.GuestResponse {
+	return protocol.GuestResponse{
+		ID:             g.ID,
+		CreatedAt:      g.CreatedAt,
+		GroupID:        g.GroupID,
+		Name:           g.Name,
+		Email:          g.Email,
+		Phone:          g.Phone,
+		Country:        g.Country,
+		DocumentNumber: g.DocumentNumber,
+		Notes:          g.Notes,
+	}
+}
+
+func newGuestRegistrationResponse(r database.GuestRegistration) protocol.GuestRegistrationResponse {
+	return protocol.GuestRegistrationResponse{
+		ID:             r.ID,
+		CreatedAt:      r.CreatedAt,
+		BookingID:      r.BookingID,
+		FirstName:      r.FirstName,
+		LastName:       r.LastName,
+		BirthDate:      r.BirthDate,
+		Nationality:    r.Nationality,
+		DocumentType:   r.DocumentType,
+		DocumentNumber: r.DocumentNumber,
+		Reported:       r.Reported,
+		ReportedAt:     r.ReportedAt,
+	}
+}
+
+func newTouristTaxRuleResponse(r database.TouristTaxRule) protocol.TouristTaxRuleResponse {
+	return protocol.TouristTaxRuleResponse{
+		ID:             r.ID,
+		CreatedAt:      r.CreatedAt,
+		GroupID:        r.GroupID,
+		PropertyID:     r.PropertyID,
+		AdultRate:      r.AdultRate,
+		ChildRate:      r.ChildRate,
+		ChildUnderAge:  r.ChildUnderAge,
+		ExemptUnderAge: r.ExemptUnderAge,
+		Currency:       r.Currency,
+		ValidFrom:      r.ValidFrom,
+		ValidTo:        r.ValidTo,
+	}
+}
+
+func newActivityResponse(a database.Activity) protocol.ActivityResponse {
+	return protocol.ActivityResponse{
+		ID:         a.ID,
+		CreatedAt:  a.CreatedAt,
+		GroupID:    a.GroupID,
+		UserID:     a.UserID,
+		Action:     a.Action,
+		EntityType: a.EntityType,
+		EntityID:   a.EntityID,
+		Summary:    a.Summary,
+	}
+}
+
+func newAttachmentResponse(a database.Attachment) protocol.AttachmentResponse {
+	return protocol.AttachmentResponse{
+		ID:          a.ID,
+		CreatedAt:   a.CreatedAt,
+		CreatedBy:   a.CreatedBy,
+		GroupID:     a.GroupID,
+		ParentType:  a.ParentType,
+		ParentID:    a.ParentID,
+		Filename:    a.Filename,
+		ContentType: a.ContentType,
+		Size:        a.Size,
+	}
+}
+
+// newWebhookResponse leaves the secret out, CreateWebhook adds it
+func newWebhookResponse(w database.Webhook) protocol.WebhookResponse {
+	return protocol.WebhookResponse{
+		ID:         w.ID,
+		CreatedAt:  w.CreatedAt,
+		CreatedBy:  w.CreatedBy,
+		GroupID:    w.GroupID,
+		URL:        w.URL,
+		EventTypes: w.EventTypes,
+		Active:     w.Active,
+	}
+}
+
+func newWebhookDeliveryResponse(d database.WebhookDelivery) protocol.WebhookDeliveryResponse {
+	return protocol.WebhookDeliveryResponse{
+		ID:             d.ID,
+		CreatedAt:      d.CreatedAt,
+		WebhookID:      d.WebhookID,
+		EventType:      d.EventType,
+		Payload:        d.Payload,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		NextAttemptAt:  d.NextAttemptAt,
+		ResponseStatus: d.ResponseStatus,
+		LastError:      d.LastError,
+		DeliveredAt:    d.DeliveredAt,
+	}
+}
@@ -1,99 +1,252 @@
 package server
 
 import (
+	"booker-be/internal/collab"
+	"booker-be/internal/config"
+	"booker-be/internal/cors"
 	"booker-be/internal/database"
+	"booker-be/internal/events"
+	"booker-be/internal/jobs"
 	"booker-be/internal/session"
-	"os"
+	"booker-be/internal/storage"
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
 
 	"github.com/gin-gonic/gin"
 )
 
 // SetupRoutes initializes the routes for the booking service
-func SetupRoutes(router *gin.Engine, db database.Service, sessionValidator session.SessionValidator) {
-	// CORS middleware with whitelisted origins
-	router.Use(func(c *gin.Context) {
-		origin := c.Request.Header.Get("Origin")
-
-		// Whitelist of allowed origins - configure based on environment
-		allowedOrigins := map[string]bool{
-			"http://localhost:5173": true, // Vite dev server
-			"http://localhost:3000": true, // Alternative dev port
-		}
+func SetupRoutes(router *gin.Engine, cfg config.Config, db database.Service, sessionValidator session.SessionValidator, blobs storage.BlobStore, hub *events.Hub, scheduler *jobs.Scheduler) {
+	registerValidators() // Rules declared on the protocol messages, checked by bindJSON
+
+	// CORS policy for the frontend; downloads also let it read the file name
+	policy := cors.Policy{
+		AllowedOrigins:   cfg.AllowedOrigins(),
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization", "Accept", "Origin", "Cache-Control", "X-Requested-With", "If-Match", "If-None-Match", "Idempotency-Key"},
+		ExposedHeaders:   []string{"ETag", "Location", "Idempotent-Replayed", nextCursorHeader},
+		AllowCredentials: true,
+		MaxAge:           cfg.CORS.MaxAge,
+	}
+	downloads := policy
+	downloads.ExposedHeaders = slices.Concat(policy.ExposedHeaders, []string{"Content-Disposition"})
+	corsMW, err := cors.New(policy,
+		cors.Rule{PathPrefix: "/attachments/", Policy: downloads},
+		cors.Rule{PathPrefix: "/registrations/", Policy: downloads},
+	)
+	if err != nil {
+		panic(err)
+	}
+	router.Use(corsMW.Handler())
 
-		// Add production frontend URL from environment variable
-		frontendURL := os.Getenv("FRONTEND_URL")
-		if frontendURL != "" {
-			allowedOrigins[frontendURL] = true
+	// Handlers are built for every request around a database whose log lines carry
+	// the request's attributes, such as the request ID and user
+	handle := func(newHandler func(database.Service) gin.HandlerFunc) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			newHandler(db.WithContext(c.Request.Context()))(c)
 		}
+	}
 
-		// Check if origin is in whitelist
-		if allowedOrigins[origin] {
-			c.Header("Access-Control-Allow-Origin", origin)
-			c.Header("Access-Control-Allow-Credentials", "true")
-		}
+	authMW := AuthMiddleware(sessionValidator) // Create the authentication middleware
+	// Replays retried POST requests; login is left out as its response holds a session token
+	idempotencyMW := handle(func(db database.Service) gin.HandlerFunc {
+		return IdempotencyMiddleware(db, cfg.Server.IdempotencyKeyTTL)
+	})
 
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Accept, Origin, Cache-Control, X-Requested-With")
-		c.Header("Content-Type", "application/json")
+	// Probes for the orchestrator and reverse proxy
+	router.GET("/healthz", GetHealth())
+	router.GET("/readyz", handle(func(db database.Service) gin.HandlerFunc { return GetReadiness(db, scheduler) }))
+	router.GET("/version", GetVersion())
+	router.GET("/metrics", GetMetrics(cfg.Metrics.Token))
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204) // No Content
-			return
-		}
-		c.Next()
-	})
+	// API documentation, built from routeDocs once every route is registered
+	var spec []byte
+	router.GET("/openapi.json", GetOpenAPI(&spec))
+	router.GET("/docs", GetDocs())
 
+	sessionStore := sessionValidator.(*session.Store) // Use type assertion to access Store methods
 	users := router.Group("/users")
 	{
-		users.POST("/register", RegisterUser(db))
-		users.POST("/login", LoginUser(db, sessionValidator.(*session.Store))) // Use type assertion to access Store methods
+		users.POST("/register", idempotencyMW, handle(func(db database.Service) gin.HandlerFunc { return RegisterUser(db, cfg.Auth.BcryptCost) }))
+		users.POST("/login", handle(func(db database.Service) gin.HandlerFunc {
+			return LoginUser(db, sessionStore, cfg.Auth.SessionDuration)
+		}))
+		users.GET("/me", authMW, handle(GetCurrentUser))
+		users.PUT("/me", authMW, handle(UpdateCurrentUser))
 	}
 
-	authMW := AuthMiddleware(sessionValidator) // Create the authentication middleware
+	notifications := router.Group("/notifications")
+	notifications.Use(authMW) // Apply authentication middleware
+	{
+		notifications.GET("/preferences", handle(GetNotificationPreferences))
+		notifications.PUT("/preferences", handle(UpdateNotificationPreferences))
+	}
 
 	bookings := router.Group("/bookings")
-	bookings.Use(authMW) // Apply authentication middleware
+	bookings.Use(authMW, idempotencyMW) // Apply authentication and idempotency middleware
+	{
+		bookings.GET("/property/:propertyID", handle(GetBookingsByPropertyID))
+		bookings.GET("/group/:groupID", handle(GetBookingsByGroupID))
+		bookings.POST("/property/:propertyID", handle(func(db database.Service) gin.HandlerFunc { return CreateBooking(db, hub) }))
+		bookings.GET("/:bookingID", handle(GetBooking))
+		bookings.PUT("/:bookingID", handle(func(db database.Service) gin.HandlerFunc { return UpdateBooking(db, hub) }))
+		bookings.PATCH("/:bookingID", handle(func(db database.Service) gin.HandlerFunc { return PatchBooking(db, hub) }))
+		bookings.DELETE("/:bookingID", handle(func(db database.Service) gin.HandlerFunc { return DeleteBooking(db, blobs, hub) }))
+		bookings.GET("/:bookingID/registrations", handle(GetGuestRegistrationsByBookingID))
+		bookings.POST("/:bookingID/registrations", handle(CreateGuestRegistration))
+		bookings.GET("/:bookingID/comments", handle(GetBookingComments))
+		bookings.POST("/:bookingID/comments", handle(CreateBookingComment))
+		bookings.GET("/:bookingID/comments/:commentID", handle(GetBookingComment))
+		bookings.PUT("/:bookingID/comments/:commentID", handle(UpdateBookingComment))
+		bookings.DELETE("/:bookingID/comments/:commentID", handle(DeleteBookingComment))
+	}
+
+	activity := router.Group("/activity")
+	activity.Use(authMW) // Apply authentication middleware
 	{
-		bookings.GET("/property/:propertyID", GetBookingsByPropertyID(db))
-		bookings.GET("/group/:groupID", GetBookingsByGroupID(db))
-		bookings.POST("/property/:propertyID", CreateBooking(db))
-		bookings.PUT("/:bookingID", UpdateBooking(db))
-		bookings.DELETE("/:bookingID", DeleteBooking(db))
+		activity.GET("/group/:groupID", handle(GetActivityByGroupID))
+	}
+
+	registrations := router.Group("/registrations")
+	registrations.Use(authMW, idempotencyMW) // Apply authentication and idempotency middleware
+	{
+		registrations.GET("/group/:groupID/export", handle(ExportGuestRegistrations))
+		registrations.POST("/group/:groupID/reported", handle(SetGuestRegistrationsReported))
+		registrations.GET("/:registrationID", handle(GetGuestRegistration))
+		registrations.PUT("/:registrationID", handle(UpdateGuestRegistration))
+		registrations.DELETE("/:registrationID", handle(DeleteGuestRegistration))
 	}
 
 	groupCodes := router.Group("/group-codes")
-	groupCodes.Use(authMW) // Apply authentication middleware
+	groupCodes.Use(authMW, idempotencyMW) // Apply authentication and idempotency middleware
 	{
-		groupCodes.POST("/", CreateGroupCode(db))
+		groupCodes.POST("/", handle(func(db database.Service) gin.HandlerFunc { return CreateGroupCode(db, cfg.Auth.GroupCodeDuration) }))
 	}
 
 	groups := router.Group("/groups")
-	groups.Use(authMW) // Apply authentication middleware
+	groups.Use(authMW, idempotencyMW) // Apply authentication and idempotency middleware
 	{
-		groups.GET("/:userID", GetGroupsByUserID(db))
-		groups.POST("/", CreateGroup(db))
-		groups.POST("/join/:code", JoinGroup(db))
+		groups.GET("/:id", handle(GetGroupsByUserID))
+		groups.GET("/group/:groupID", handle(GetGroup))
+		groups.POST("/", handle(CreateGroup))
+		groups.POST("/join/:code", handle(JoinGroup))
+		// The group ID is :id because /groups/:id takes the same path segment
+		groups.GET("/:id/search", handle(SearchGroup))
 	}
 
+	// Event streams also accept the token as a query parameter, see StreamAuthMiddleware
+	streamAuthMW := StreamAuthMiddleware(sessionValidator)
+	presence := collab.NewPresence()
+	router.GET("/groups/:id/events", streamAuthMW, handle(func(db database.Service) gin.HandlerFunc { return StreamGroupEvents(db, hub) }))
+	router.GET("/groups/:id/ws", streamAuthMW, handle(func(db database.Service) gin.HandlerFunc { return GroupSocket(db, hub, presence, corsMW) }))
+
 	properties := router.Group("/properties")
-	properties.Use(authMW) // Apply authentication middleware
+	properties.Use(authMW, idempotencyMW) // Apply authentication and idempotency middleware
 	{
-		properties.GET("/group/:groupID", GetPropertiesByGroupID(db))
-		properties.POST("/group/:groupID", CreateProperty(db))
-		properties.PUT("/:propertyID", UpdateProperty(db))
+		properties.GET("/group/:groupID", handle(GetPropertiesByGroupID))
+		properties.POST("/group/:groupID", handle(func(db database.Service) gin.HandlerFunc { return CreateProperty(db, hub) }))
+		properties.GET("/:propertyID", handle(GetProperty))
+		properties.PUT("/:propertyID", handle(func(db database.Service) gin.HandlerFunc { return UpdateProperty(db, hub) }))
+		properties.DELETE("/:propertyID", handle(func(db database.Service) gin.HandlerFunc { return DeleteProperty(db, blobs, hub) }))
+	}
+
+	attachments := router.Group("/attachments")
+	attachments.Use(authMW, idempotencyMW) // Apply authentication and idempotency middleware
+	{
+		attachments.GET("/booking/:bookingID", handle(GetBookingAttachments))
+		attachments.POST("/booking/:bookingID", handle(func(db database.Service) gin.HandlerFunc { return UploadBookingAttachment(db, blobs) }))
+		attachments.GET("/property/:propertyID", handle(GetPropertyAttachments))
+		attachments.POST("/property/:propertyID", handle(func(db database.Service) gin.HandlerFunc { return UploadPropertyAttachment(db, blobs) }))
+		attachments.GET("/:attachmentID", handle(func(db database.Service) gin.HandlerFunc { return DownloadAttachment(db, blobs) }))
+		attachments.DELETE("/:attachmentID", handle(func(db database.Service) gin.HandlerFunc { return DeleteAttachment(db, blobs) }))
+	}
+
+	guests := router.Group("/guests")
+	guests.Use(authMW, idempotencyMW) // Apply authentication and idempotency middleware
+	{
+		guests.GET("/group/:groupID", handle(GetGuestsByGroupID))
+		guests.GET("/group/:groupID/search", handle(SearchGuests))
+		guests.POST("/group/:groupID", handle(CreateGuest))
+		guests.GET("/:guestID", handle(GetGuestByID))
+		guests.PUT("/:guestID", handle(UpdateGuest))
+		guests.DELETE("/:guestID", handle(DeleteGuest))
+	}
+
+	touristTax := router.Group("/tourist-tax")
+	touristTax.Use(authMW, idempotencyMW) // Apply authentication and idempotency middleware
+	{
+		touristTax.GET("/group/:groupID", handle(GetTouristTaxRulesByGroupID))
+		touristTax.POST("/group/:groupID", handle(CreateTouristTaxRule))
+		touristTax.GET("/group/:groupID/report", handle(GetTouristTaxReport))
+		touristTax.GET("/booking/:bookingID", handle(GetBookingTouristTax))
+		touristTax.GET("/:ruleID", handle(GetTouristTaxRule))
+		touristTax.PUT("/:ruleID", handle(UpdateTouristTaxRule))
+		touristTax.DELETE("/:ruleID", handle(DeleteTouristTaxRule))
+	}
+
+	webhooks := router.Group("/webhooks")
+	webhooks.Use(authMW, idempotencyMW) // Apply authentication and idempotency middleware
+	{
+		webhooks.GET("/group/:groupID", handle(GetWebhooksByGroupID))
+		webhooks.POST("/group/:groupID", handle(CreateWebhook))
+		webhooks.GET("/:webhookID", handle(GetWebhook))
+		webhooks.PUT("/:webhookID", handle(UpdateWebhook))
+		webhooks.DELETE("/:webhookID", handle(DeleteWebhook))
+		webhooks.GET("/:webhookID/deliveries", handle(GetWebhookDeliveries))
+	}
+
+	admin := router.Group("/admin")
+	admin.Use(authMW, AdminMiddleware(cfg.Auth.AdminUserIDs), idempotencyMW)
+	{
+		admin.GET("/jobs", GetJobs(scheduler))
+		admin.POST("/jobs/:name/run", RunJob(scheduler))
 	}
 
 	router.NoRoute(func(c *gin.Context) {
-		c.JSON(404, gin.H{"message": "Not Found"})
+		respondError(c, CodeNotFound, "Route not found")
 	})
-}
 
-// StartServer initializes the Gin router and starts the server
-func StartServer(db database.Service, sessionStore session.SessionValidator) {
-	router := gin.Default()
-	SetupRoutes(router, db, sessionStore)
+	// A route added without documenting it in routeDocs is left out of the document,
+	// and fails TestEveryRouteIsDocumented
+	spec, err = buildOpenAPI(router.Routes())
+	if err != nil {
+		slog.Error("Failed to document the API", "error", err)
+	}
+}
 
-	if err := router.Run(":8080"); err != nil {
-		panic("Failed to start server: " + err.Error())
+// StartServer serves the API until ctx is cancelled, then shuts down gracefully: it stops
+// accepting connections, ends event streams and WebSockets, and waits up to
+// the shutdown timeout for in-flight requests to complete
+func StartServer(ctx context.Context, cfg config.Config, db database.Service, sessionStore session.SessionValidator, blobs storage.BlobStore, hub *events.Hub, scheduler *jobs.Scheduler) error {
+	router := gin.New()
+	router.Use(RequestIDMiddleware(), MetricsMiddleware(), LoggerMiddleware(), RecoveryMiddleware())
+	SetupRoutes(router, cfg, db, sessionStore, blobs, hub, scheduler)
+
+	srv := &http.Server{
+		Addr:              cfg.Server.Addr,
+		Handler:           router,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
 	}
+	// Streams never finish on their own and would hold the shutdown up until it times out
+	srv.RegisterOnShutdown(hub.Close)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
 }
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"booker-be/internal/database"
+	"booker-be/internal/protocol"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// SearchGroup finds the bookings, guests and properties of a group matching a
+// search (?q=&limit=), best matches first
+func SearchGroup(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		groupID := c.Param("id")
+
+		// Check if user belongs to this group
+		if !db.UserBelongsToGroup(userID.(string), groupID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this group")
+			return
+		}
+
+		query := strings.TrimSpace(c.Query("q"))
+		if query == "" {
+			abortWithError(c, invalidField("q", fieldRequired, "Search query is required"))
+			return
+		}
+
+		limit := defaultSearchLimit
+		if l := c.Query("limit"); l != "" {
+			n, err := strconv.Atoi(l)
+			if err != nil || n < 1 {
+				abortWithError(c, invalidField("limit", fieldOutOfRange, "Invalid limit"))
+				return
+			}
+			limit = min(n, maxSearchLimit)
+		}
+
+		results, err := db.SearchGroup(groupID, query, limit)
+		if err != nil {
+			respondError(c, CodeInternal, "Failed to search")
+			return
+		}
+		c.JSON(200, protocol.SearchResponse{
+			Bookings:   mapAll(results.Bookings, newBookingResponse),
+			Guests:     mapAll(results.Guests, newGuestResponse),
+			Properties: mapAll(results.Properties, newPropertyResponse),
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package server
+
+import (
+	"booker-be/internal/database"
+	"booker-be/internal/protocol"
+	"booker-be/internal/touristtax"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultChildUnderAge = 18
+
+func GetTouristTaxRulesByGroupID(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		groupID := c.Param("groupID")
+
+		// Check if user belongs to this group
+		if !db.UserBelongsToGroup(userID.(string), groupID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this group")
+			return
+		}
+
+		rules, err := db.GetTouristTaxRulesByGroupID(groupID)
+		if err != nil {
+			respondError(c, CodeInternal, "Failed to retrieve tourist tax rules")
+			return
+		}
+		c.JSON(200, mapAll(rules, newTouristTaxRuleResponse))
+	}
+}
+
+func CreateTouristTaxRule(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		var rule protocol.TouristTaxRuleMessage
+		if !bindJSON(c, &rule) {
+			return
+		}
+
+		groupID := c.Param("groupID")
+
+		// Check if user belongs to this group
+		if !db.UserBelongsToGroup(userID.(string), groupID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this group")
+			return
+		}
+
+		if err := validateTouristTaxRule(db, groupID, &rule); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		r := database.TouristTaxRule{
+			ID:             protocol.GenerateID(),
+			CreatedAt:      protocol.GetCurrentTime(),
+			GroupID:        groupID,
+			PropertyID:     rule.PropertyID,
+			AdultRate:      rule.AdultRate,
+			ChildRate:      rule.ChildRate,
+			ChildUnderAge:  rule.ChildUnderAge,
+			ExemptUnderAge: rule.ExemptUnderAge,
+			Currency:       rule.Currency,
+			ValidFrom:      rule.ValidFrom,
+			ValidTo:        rule.ValidTo,
+		}
+
+		if err := db.InsertTouristTaxRule(r); err != nil {
+			respondError(c, CodeInternal, "Failed to create tourist tax rule")
+			return
+		}
+		respondCreated(c, "/tourist-tax/"+r.ID, newTouristTaxRuleResponse(r))
+	}
+}
+
+func GetTouristTaxRule(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		rule, err := db.GetTouristTaxRuleByID(c.Param("ruleID"))
+		if err != nil {
+			respondError(c, CodeNotFound, "Tourist tax rule not found")
+			return
+		}
+
+		// Check if user belongs to the rule's group
+		if !db.UserBelongsToGroup(userID.(string), rule.GroupID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this group")
+			return
+		}
+
+		c.JSON(200, newTouristTaxRuleResponse(rule))
+	}
+}
+
+func UpdateTouristTaxRule(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		var rule protocol.TouristTaxRuleMessage
+		if !bindJSON(c, &rule) {
+			return
+		}
+
+		existing, err := db.GetTouristTaxRuleByID(c.Param("ruleID"))
+		if err != nil {
+			respondError(c, CodeNotFound, "Tourist tax rule not found")
+			return
+		}
+
+		// Check if user belongs to the rule's group
+		if !db.UserBelongsToGroup(userID.(string), existing.GroupID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this group")
+			return
+		}
+
+		if err := validateTouristTaxRule(db, existing.GroupID, &rule); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		existing.PropertyID = rule.PropertyID
+		existing.AdultRate = rule.AdultRate
+		existing.ChildRate = rule.ChildRate
+		existing.ChildUnderAge = rule.ChildUnderAge
+		existing.ExemptUnderAge = rule.ExemptUnderAge
+		existing.Currency = rule.Currency
+		existing.ValidFrom = rule.ValidFrom
+		existing.ValidTo = rule.ValidTo
+
+		if err := db.UpdateTouristTaxRule(existing); err != nil {
+			respondError(c, CodeInternal, "Failed to update tourist tax rule")
+			return
+		}
+		c.JSON(200, newTouristTaxRuleResponse(existing))
+	}
+}
+
+func DeleteTouristTaxRule(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		ruleID := c.Param("ruleID")
+		existing, err := db.GetTouristTaxRuleByID(ruleID)
+		if err != nil {
+			respondError(c, CodeNotFound, "Tourist tax rule not found")
+			return
+		}
+
+		// Check if user belongs to the rule's group
+		if !db.UserBelongsToGroup(userID.(string), existing.GroupID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this group")
+			return
+		}
+
+		if err := db.DeleteTouristTaxRule(ruleID); err != nil {
+			respondError(c, CodeInternal, "Failed to delete tourist tax rule")
+			return
+		}
+		c.JSON(200, gin.H{"message": "Tourist tax rule deleted successfully"})
+	}
+}
+
+// GetBookingTouristTax computes the tourist tax owed for a whole booking
+func GetBookingTouristTax(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		bookingID := c.Param("bookingID")
+
+		// Check if user can access this booking
+		if !db.UserCanAccessBooking(userID.(string), bookingID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this booking")
+			return
+		}
+
+		booking, err := db.GetBookingByID(bookingID)
+		if err != nil {
+			respondError(c, CodeNotFound, "Booking not found")
+			return
+		}
+
+		property, err := db.GetPropertyByID(booking.PropertyID)
+		if err != nil {
+			respondError(c, CodeNotFound, "Property not found")
+			return
+		}
+
+		rules, err := db.GetTouristTaxRulesByGroupID(property.GroupID)
+		if err != nil {
+			respondError(c, CodeInternal, "Failed to retrieve tourist tax rules")
+			return
+		}
+
+		registrations, err := db.GetGuestRegistrationsByBookingID(bookingID)
+		if err != nil {
+			respondError(c, CodeInternal, "Failed to retrieve guest registrations")
+			return
+		}
+
+		assessment, err := touristtax.Assess(booking, registrations, rules, time.Time{}, time.Time{})
+		if err != nil {
+			respondError(c, CodeUnprocessable, "Booking dates are invalid")
+			return
+		}
+		c.JSON(200, assessment)
+	}
+}
+
+// GetTouristTaxReport totals the tourist tax owed per property for a month (?month=YYYY-MM)
+func GetTouristTaxReport(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		groupID := c.Param("groupID")
+
+		// Check if user belongs to this group
+		if !db.UserBelongsToGroup(userID.(string), groupID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this group")
+			return
+		}
+
+		month := c.Query("month")
+		from, to, err := touristtax.MonthBounds(month)
+		if err != nil {
+			abortWithError(c, invalidField("month", fieldInvalidFormat, "Invalid month format. Expected YYYY-MM"))
+			return
+		}
+
+		properties, err := db.GetPropertiesByGroupID(groupID)
+		if err != nil {
+			respondError(c, CodeInternal, "Failed to retrieve properties")
+			return
+		}
+
+		propertyIDs := make([]string, len(properties))
+		for i, p := range properties {
+			propertyIDs[i] = p.ID
+		}
+
+		bookings, err := db.GetBookingsByPropertyIdsInRange(propertyIDs, from.Format("2006-01-02"), to.Format("2006-01-02"))
+		if err != nil {
+			respondError(c, CodeInternal, "Failed to retrieve bookings")
+			return
+		}
+
+		rules, err := db.GetTouristTaxRulesByGroupID(groupID)
+		if err != nil {
+			respondError(c, CodeInternal, "Failed to retrieve tourist tax rules")
+			return
+		}
+
+		bookingIDs := make([]string, len(bookings))
+		for i, b := range bookings {
+			bookingIDs[i] = b.ID
+		}
+
+		registrations, err := db.GetGuestRegistrationsByBookingIDs(bookingIDs)
+		if err != nil {
+			respondError(c, CodeInternal, "Failed to retrieve guest registrations")
+			return
+		}
+
+		report, err := touristtax.NewReport(groupID, month, properties, bookings, registrations, rules)
+		if err != nil {
+			respondError(c, CodeInternal, "Failed to compute tourist tax report")
+			return
+		}
+		c.JSON(200, report)
+	}
+}
+
+// validateTouristTaxRule checks a rule message and fills in defaults.
+// It returns nil if the rule is valid.
+func validateTouristTaxRule(db database.Service, groupID string, rule *protocol.TouristTaxRuleMessage) *APIError {
+	if rule.ChildUnderAge == 0 {
+		rule.ChildUnderAge = defaultChildUnderAge
+	}
+	if rule.ExemptUnderAge > rule.ChildUnderAge {
+		return invalidField("exempt_under_age", fieldOutOfRange, "Exemption age cannot be above the child age limit")
+	}
+	if rule.Currency == "" {
+		rule.Currency = "EUR"
+	}
+	if rule.PropertyID != "" {
+		property, err := db.GetPropertyByID(rule.PropertyID)
+		if err != nil || property.GroupID != groupID {
+			return invalidField("property_id", fieldInvalidValue, "Property does not belong to this group")
+		}
+	}
+	return nil
+}
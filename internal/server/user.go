@@ -9,24 +9,23 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterUser(db database.Service) gin.HandlerFunc {
+func RegisterUser(db database.Service, bcryptCost int) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var user protocol.CreateUserMessage
-		if err := c.ShouldBindJSON(&user); err != nil {
-			c.JSON(400, gin.H{"error": "Invalid input"})
+		if !bindJSON(c, &user) {
 			return
 		}
 
 		// Check if the username already exists
 		existingUser, err := db.GetUserByUsername(user.Username)
 		if err == nil && existingUser.Username != "" {
-			c.JSON(409, gin.H{"error": "Username already exists"})
+			respondError(c, CodeUsernameTaken, "Username already exists")
 			return
 		}
 
-		hashedPassword, err := protocol.HashPassword(user.Password)
+		hashedPassword, err := protocol.HashPassword(user.Password, bcryptCost)
 		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to hash password"})
+			respondError(c, CodeInternal, "Failed to hash password")
 			return
 		}
 
@@ -36,39 +35,40 @@ func RegisterUser(db database.Service) gin.HandlerFunc {
 			ID:             id,
 			Username:       user.Username,
 			HashedPassword: hashedPassword,
+			Email:          user.Email,
 		})
 
 		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to register user"})
+			respondError(c, CodeInternal, "Failed to register user")
 			return
 		}
 
-		c.JSON(201, gin.H{"message": "User registered successfully"})
+		// A user is read at /users/me, as that user
+		respondCreated(c, "/users/me", newUserResponse(database.User{ID: id, Username: user.Username, Email: user.Email}))
 	}
 }
 
-func LoginUser(db database.Service, sessionStore *session.Store) gin.HandlerFunc {
+func LoginUser(db database.Service, sessionStore *session.Store, sessionDuration time.Duration) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var user protocol.LoginUserMessage
-		if err := c.ShouldBindJSON(&user); err != nil {
-			c.JSON(400, gin.H{"error": "Invalid input"})
+		if !bindJSON(c, &user) {
 			return
 		}
 
 		dbUser, err := db.GetUserByUsername(user.Username)
 		if err != nil {
-			c.JSON(401, gin.H{"error": "Invalid username or password"})
+			respondError(c, CodeInvalidCredentials, "Invalid username or password")
 			return
 		}
 
 		if !protocol.CheckPasswordHash(user.Password, dbUser.HashedPassword) {
-			c.JSON(401, gin.H{"error": "Invalid username or password"})
+			respondError(c, CodeInvalidCredentials, "Invalid username or password")
 			return
 		}
 
-		token, err := sessionStore.CreateSession(dbUser.ID, time.Hour*24) // Create a session valid for 24 hours
+		token, err := sessionStore.CreateSession(dbUser.ID, sessionDuration)
 		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to create session"})
+			respondError(c, CodeInternal, "Failed to create session")
 			return
 		}
 
@@ -80,3 +80,51 @@ func LoginUser(db database.Service, sessionStore *session.Store) gin.HandlerFunc
 		})
 	}
 }
+
+// GetCurrentUser returns the profile of the authenticated user
+func GetCurrentUser(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		user, err := db.GetUserByID(userID.(string))
+		if err != nil {
+			respondError(c, CodeNotFound, "User not found")
+			return
+		}
+
+		c.JSON(200, newUserResponse(user))
+	}
+}
+
+// UpdateCurrentUser changes the email address of the authenticated user.
+// An empty address turns all email notifications off.
+func UpdateCurrentUser(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		var update protocol.UpdateUserMessage
+		if !bindJSON(c, &update) {
+			return
+		}
+
+		if err := db.UpdateUserEmail(userID.(string), update.Email); err != nil {
+			respondError(c, CodeInternal, "Failed to update user")
+			return
+		}
+
+		user, err := db.GetUserByID(userID.(string))
+		if err != nil {
+			respondError(c, CodeNotFound, "User not found")
+			return
+		}
+		c.JSON(200, newUserResponse(user))
+	}
+}
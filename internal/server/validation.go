@@ -0,0 +1,113 @@
+package server
+
+import (
+	"booker-be/internal/protocol"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// registerValidators adds the protocol rules to the validator gin binds with
+func registerValidators() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		panic("unexpected gin validator engine")
+	}
+	if err := protocol.RegisterValidators(v); err != nil {
+		panic(err)
+	}
+}
+
+// bindJSON decodes the request body into msg and checks the rules declared on it.
+// It responds with the invalid fields and returns false when it fails.
+func bindJSON(c *gin.Context, msg any) bool {
+	err := c.ShouldBindJSON(msg)
+	if err == nil {
+		return true
+	}
+
+	var invalid validator.ValidationErrors
+	if !errors.As(err, &invalid) {
+		respondError(c, CodeInvalidInput, "Invalid input")
+		return false
+	}
+
+	apiErr := &APIError{Code: CodeValidationFailed, Message: "Invalid input"}
+	for _, fe := range invalid {
+		apiErr.Details = append(apiErr.Details, fieldErrorFor(msg, fe))
+	}
+	if len(apiErr.Details) == 1 {
+		apiErr.Message = apiErr.Details[0].Message
+	}
+	abortWithError(c, apiErr)
+	return false
+}
+
+// fieldErrorFor translates a failed rule into a field error
+func fieldErrorFor(msg any, fe validator.FieldError) FieldError {
+	field := fe.Field()
+	kind := fe.Kind()
+	isString := kind == reflect.String
+	isList := kind == reflect.Slice || kind == reflect.Array || kind == reflect.Map
+
+	switch fe.Tag() {
+	case "required", "notblank":
+		return FieldError{field, fieldRequired, field + " is required"}
+	case "min", "gte":
+		switch {
+		case isString:
+			return FieldError{field, fieldTooShort, fmt.Sprintf("%s must be at least %s characters long", field, fe.Param())}
+		case isList:
+			return FieldError{field, fieldTooShort, fmt.Sprintf("%s must have at least %s items", field, fe.Param())}
+		}
+		return FieldError{field, fieldOutOfRange, fmt.Sprintf("%s must be at least %s", field, fe.Param())}
+	case "max", "lte":
+		switch {
+		case isString:
+			return FieldError{field, fieldTooLong, fmt.Sprintf("%s must be at most %s characters long", field, fe.Param())}
+		case isList:
+			return FieldError{field, fieldTooLong, fmt.Sprintf("%s must have at most %s items", field, fe.Param())}
+		}
+		return FieldError{field, fieldOutOfRange, fmt.Sprintf("%s must be at most %s", field, fe.Param())}
+	case "len":
+		return FieldError{field, fieldInvalidFormat, fmt.Sprintf("%s must be %s characters long", field, fe.Param())}
+	case "date":
+		return FieldError{field, fieldInvalidFormat, field + " must be a date formatted as YYYY-MM-DD"}
+	case "notbefore":
+		return FieldError{field, fieldOutOfRange, fmt.Sprintf("%s must not be before %s", field, jsonName(msg, fe.Param()))}
+	case "notfuture":
+		return FieldError{field, fieldOutOfRange, field + " must not be in the future"}
+	case "iso3166_1_alpha2":
+		return FieldError{field, fieldInvalidFormat, field + " must be an ISO 3166 country code such as DE"}
+	case "oneof":
+		return FieldError{field, fieldInvalidValue, fmt.Sprintf("%s must be one of %s", field, strings.Join(strings.Fields(fe.Param()), ", "))}
+	case "uuid":
+		return FieldError{field, fieldInvalidFormat, field + " must be a valid ID"}
+	case "rgbhex":
+		return FieldError{field, fieldInvalidFormat, field + " must be a hex color such as #FF5733"}
+	case "url":
+		return FieldError{field, fieldInvalidFormat, field + " must be a valid URL"}
+	case "email":
+		return FieldError{field, fieldInvalidFormat, field + " must be an email address such as ann@example.com"}
+	}
+	return FieldError{field, fieldInvalidValue, field + " is invalid"}
+}
+
+// jsonName returns the JSON name of the named field of msg, a pointer to a struct
+func jsonName(msg any, goName string) string {
+	t := reflect.TypeOf(msg)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct {
+		if f, ok := t.FieldByName(goName); ok {
+			return protocol.JSONFieldName(f)
+		}
+	}
+	return goName
+}
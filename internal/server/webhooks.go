@@ -0,0 +1,260 @@
+package server
+
+import (
+	"booker-be/internal/database"
+	"booker-be/internal/protocol"
+	"booker-be/internal/webhooks"
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 200
+)
+
+func GetWebhooksByGroupID(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		groupID := c.Param("groupID")
+
+		// Check if user belongs to this group
+		if !db.UserBelongsToGroup(userID.(string), groupID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this group")
+			return
+		}
+
+		hooks, err := db.GetWebhooksByGroupID(groupID)
+		if err != nil {
+			respondError(c, CodeInternal, "Failed to retrieve webhooks")
+			return
+		}
+		c.JSON(200, mapAll(hooks, newWebhookResponse))
+	}
+}
+
+// CreateWebhook subscribes a URL to booking events of a group. The signing secret
+// is only returned here, so callers that let the server generate it must keep it.
+func CreateWebhook(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		var hook protocol.WebhookMessage
+		if !bindJSON(c, &hook) {
+			return
+		}
+
+		groupID := c.Param("groupID")
+
+		// Check if user belongs to this group
+		if !db.UserBelongsToGroup(userID.(string), groupID) {
+			respondError(c, CodeForbidden, "Forbidden: You don't have access to this group")
+			return
+		}
+
+		if err := validateWebhook(&hook); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		if hook.Secret == "" {
+			secret, err := generateWebhookSecret()
+			if err != nil {
+				respondError(c, CodeInternal, "Failed to generate webhook secret")
+				return
+			}
+			hook.Secret = secret
+		}
+
+		w := database.Webhook{
+			ID:         protocol.GenerateID(),
+			CreatedAt:  protocol.GetCurrentTime(),
+			CreatedBy:  userID.(string),
+			GroupID:    groupID,
+			URL:        hook.URL,
+			EventTypes: hook.EventTypes,
+			Secret:     hook.Secret,
+			Active:     hook.Active == nil || *hook.Active,
+		}
+
+		if err := db.InsertWebhook(w); err != nil {
+			respondError(c, CodeInternal, "Failed to create webhook")
+			return
+		}
+
+		created := newWebhookResponse(w)
+		created.Secret = w.Secret
+		respondCreated(c, "/webhooks/"+w.ID, created)
+	}
+}
+
+func GetWebhook(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		hook, ok := accessibleWebhook(c, db, userID.(string))
+		if !ok {
+			return
+		}
+		c.JSON(200, newWebhookResponse(hook))
+	}
+}
+
+func UpdateWebhook(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		var hook protocol.WebhookMessage
+		if !bindJSON(c, &hook) {
+			return
+		}
+
+		existing, ok := accessibleWebhook(c, db, userID.(string))
+		if !ok {
+			return
+		}
+
+		if err := validateWebhook(&hook); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		existing.URL = hook.URL
+		existing.EventTypes = hook.EventTypes
+		if hook.Secret != "" {
+			existing.Secret = hook.Secret
+		}
+		if hook.Active != nil {
+			existing.Active = *hook.Active
+		}
+
+		if err := db.UpdateWebhook(existing); err != nil {
+			respondError(c, CodeInternal, "Failed to update webhook")
+			return
+		}
+
+		c.JSON(200, newWebhookResponse(existing))
+	}
+}
+
+func DeleteWebhook(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		existing, ok := accessibleWebhook(c, db, userID.(string))
+		if !ok {
+			return
+		}
+
+		if err := db.DeleteWebhook(existing.ID); err != nil {
+			respondError(c, CodeInternal, "Failed to delete webhook")
+			return
+		}
+
+		c.JSON(200, gin.H{"message": "Webhook deleted successfully"})
+	}
+}
+
+// GetWebhookDeliveries returns the delivery log of a webhook, newest first (?limit=)
+func GetWebhookDeliveries(db database.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			respondError(c, CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		existing, ok := accessibleWebhook(c, db, userID.(string))
+		if !ok {
+			return
+		}
+
+		limit := defaultDeliveryLimit
+		if l := c.Query("limit"); l != "" {
+			n, err := strconv.Atoi(l)
+			if err != nil || n < 1 {
+				abortWithError(c, invalidField("limit", fieldOutOfRange, "Invalid limit"))
+				return
+			}
+			limit = min(n, maxDeliveryLimit)
+		}
+
+		deliveries, err := db.GetWebhookDeliveriesByWebhookID(existing.ID, limit)
+		if err != nil {
+			respondError(c, CodeInternal, "Failed to retrieve deliveries")
+			return
+		}
+		c.JSON(200, mapAll(deliveries, newWebhookDeliveryResponse))
+	}
+}
+
+// accessibleWebhook loads the webhook named in the URL and checks that the user belongs
+// to its group. On failure it writes the response and returns false.
+func accessibleWebhook(c *gin.Context, db database.Service, userID string) (database.Webhook, bool) {
+	hook, err := db.GetWebhookByID(c.Param("webhookID"))
+	if err != nil {
+		respondError(c, CodeNotFound, "Webhook not found")
+		return database.Webhook{}, false
+	}
+
+	// Check if user belongs to the webhook's group
+	if !db.UserBelongsToGroup(userID, hook.GroupID) {
+		respondError(c, CodeForbidden, "Forbidden: You don't have access to this webhook")
+		return database.Webhook{}, false
+	}
+
+	return hook, true
+}
+
+// validateWebhook checks a webhook message and normalizes its event types
+func validateWebhook(hook *protocol.WebhookMessage) *APIError {
+	hook.URL = strings.TrimSpace(hook.URL)
+	u, err := url.Parse(hook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return invalidField("url", fieldInvalidFormat, "A valid http or https URL is required")
+	}
+
+	for _, eventType := range hook.EventTypes {
+		if !webhooks.IsEventType(eventType) {
+			return invalidField("event_types", fieldInvalidValue, "Unknown event type "+strconv.Quote(eventType)+". Allowed: "+strings.Join(webhooks.EventTypes, ", "))
+		}
+	}
+	slices.Sort(hook.EventTypes)
+	hook.EventTypes = slices.Compact(hook.EventTypes)
+	return nil
+}
+
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -22,12 +22,9 @@ type Store struct {
 
 // NewStore creates a new in-memory session store
 func NewStore() *Store {
-	s := &Store{
+	return &Store{
 		sessions: make(map[string]SessionData),
 	}
-	// Periodically clean up expired sessions (optional, but good practice)
-	go s.cleanupExpiredSessions()
-	return s
 }
 
 // GenerateToken creates a new random session token
@@ -86,17 +83,30 @@ func (s *Store) DeleteSession(tokenString string) {
 	delete(s.sessions, tokenString)
 }
 
-func (s *Store) cleanupExpiredSessions() {
-	for {
-		time.Sleep(10 * time.Minute) // Check every 10 minutes
-		s.mu.Lock()
-		for token, data := range s.sessions {
-			if time.Now().After(data.ExpiresAt) {
-				delete(s.sessions, token)
-			}
+// CleanupExpiredSessions removes all expired sessions. Expired tokens are rejected
+// anyway, this only frees their memory; it is run periodically by the job scheduler.
+func (s *Store) CleanupExpiredSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for token, data := range s.sessions {
+		if time.Now().After(data.ExpiresAt) {
+			delete(s.sessions, token)
+		}
+	}
+}
+
+// ActiveSessions counts the sessions that have not expired
+func (s *Store) ActiveSessions() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := time.Now()
+	count := 0
+	for _, data := range s.sessions {
+		if now.Before(data.ExpiresAt) {
+			count++
 		}
-		s.mu.Unlock()
 	}
+	return count
 }
 
 // Define an interface for cleaner dependency injection if preferred
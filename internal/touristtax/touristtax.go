@@ -0,0 +1,273 @@
+package touristtax
+
+import (
+	"booker-be/internal/database"
+	"booker-be/internal/protocol"
+	"errors"
+	"math"
+	"time"
+)
+
+const (
+	CategoryAdult  = "adult"
+	CategoryChild  = "child"
+	CategoryExempt = "exempt"
+)
+
+// Line is a single row of an assessment: guest nights of one category charged at one rate
+type Line struct {
+	Category    string  `json:"category"`
+	Rate        float64 `json:"rate"`
+	Currency    string  `json:"currency"`
+	GuestNights int     `json:"guest_nights"`
+	Amount      float64 `json:"amount"`
+}
+
+// Total is an amount owed in one currency. Rules may be in different currencies,
+// so totals are kept per currency rather than summed.
+type Total struct {
+	Currency string  `json:"currency"`
+	Amount   float64 `json:"amount"`
+}
+
+// Assessment is the tourist tax owed for a booking within a period
+type Assessment struct {
+	BookingID          string  `json:"booking_id"`
+	PropertyID         string  `json:"property_id"`
+	Nights             int     `json:"nights"`
+	UntaxedNights      int     `json:"untaxed_nights"` // nights for which no rule was in force
+	Lines              []Line  `json:"lines"`
+	Totals             []Total `json:"totals"`
+	TaxableGuestNights int     `json:"taxable_guest_nights"`
+}
+
+// RuleFor picks the rule in force for a property on the given night (YYYY-MM-DD).
+// A property specific rule wins over a group wide one; among equals, the one
+// that became valid most recently wins.
+func RuleFor(rules []database.TouristTaxRule, propertyID, night string) (database.TouristTaxRule, bool) {
+	var best database.TouristTaxRule
+	found := false
+	for _, r := range rules {
+		if r.PropertyID != "" && r.PropertyID != propertyID {
+			continue
+		}
+		if r.ValidFrom != "" && night < r.ValidFrom {
+			continue
+		}
+		if r.ValidTo != "" && night > r.ValidTo {
+			continue
+		}
+		if !found {
+			best, found = r, true
+			continue
+		}
+		if (r.PropertyID != "") != (best.PropertyID != "") {
+			if r.PropertyID != "" {
+				best = r
+			}
+			continue
+		}
+		if r.ValidFrom > best.ValidFrom {
+			best = r
+		}
+	}
+	return best, found
+}
+
+// Assess computes the tax owed for the nights of a booking that fall between
+// from (inclusive) and to (exclusive). Zero times mean the whole stay.
+// Each registered guest is charged by their age on arrival. Guests of the booking's
+// adult and child counts beyond the registered ones are charged by head count.
+func Assess(b database.Booking, registrations []database.GuestRegistration, rules []database.TouristTaxRule, from, to time.Time) (Assessment, error) {
+	start, err := protocol.ParseDate(b.StartDate)
+	if err != nil {
+		return Assessment{}, err
+	}
+	end, err := protocol.ParseDate(b.EndDate)
+	if err != nil {
+		return Assessment{}, err
+	}
+	if end.Before(start) {
+		return Assessment{}, errors.New("booking ends before it starts")
+	}
+
+	a := Assessment{
+		BookingID:  b.ID,
+		PropertyID: b.PropertyID,
+		Lines:      []Line{},
+		Totals:     []Total{},
+	}
+	lines := map[Line]int{} // keyed by category and rate, value is index into a.Lines
+
+	ages := make([]int, 0, len(registrations))
+	for _, r := range registrations {
+		birthDate, err := protocol.ParseDate(r.BirthDate)
+		if err != nil {
+			return Assessment{}, err
+		}
+		ages = append(ages, ageOn(birthDate, start))
+	}
+
+	add := func(category string, rate float64, currency string, guests int) {
+		if guests <= 0 {
+			return
+		}
+		key := Line{Category: category, Rate: rate, Currency: currency}
+		i, ok := lines[key]
+		if !ok {
+			i = len(a.Lines)
+			lines[key] = i
+			a.Lines = append(a.Lines, key)
+		}
+		a.Lines[i].GuestNights += guests
+		if category != CategoryExempt {
+			a.TaxableGuestNights += guests
+		}
+	}
+
+	for night := start; night.Before(end); night = night.AddDate(0, 0, 1) {
+		if !from.IsZero() && night.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !night.Before(to) {
+			break
+		}
+		a.Nights++
+
+		rule, ok := RuleFor(rules, b.PropertyID, night.Format("2006-01-02"))
+		if !ok {
+			a.UntaxedNights++
+			continue
+		}
+		registeredChildren := 0
+		for _, age := range ages {
+			switch {
+			case age < rule.ExemptUnderAge:
+				add(CategoryExempt, 0, rule.Currency, 1)
+				registeredChildren++
+			case age < rule.ChildUnderAge:
+				add(CategoryChild, rule.ChildRate, rule.Currency, 1)
+				registeredChildren++
+			default:
+				add(CategoryAdult, rule.AdultRate, rule.Currency, 1)
+			}
+		}
+
+		// Only head counts are known for the guests who aren't registered, so
+		// those children are charged the child rate and no age exemption applies
+		unregistered := max(0, b.Adults+b.Children-len(ages))
+		children := min(max(0, b.Children-registeredChildren), unregistered)
+		add(CategoryAdult, rule.AdultRate, rule.Currency, unregistered-children)
+		add(CategoryChild, rule.ChildRate, rule.Currency, children)
+	}
+
+	for i := range a.Lines {
+		a.Lines[i].Amount = round(a.Lines[i].Rate * float64(a.Lines[i].GuestNights))
+		a.Totals = addTotal(a.Totals, a.Lines[i].Currency, a.Lines[i].Amount)
+	}
+
+	return a, nil
+}
+
+// PropertyReport totals the tax owed for one property over a report period
+type PropertyReport struct {
+	PropertyID         string  `json:"property_id"`
+	PropertyName       string  `json:"property_name"`
+	Bookings           int     `json:"bookings"`
+	Nights             int     `json:"nights"`
+	TaxableGuestNights int     `json:"taxable_guest_nights"`
+	UntaxedNights      int     `json:"untaxed_nights"`
+	Totals             []Total `json:"totals"`
+}
+
+// Report is the tourist tax owed by a group for one calendar month
+type Report struct {
+	GroupID    string           `json:"group_id"`
+	Month      string           `json:"month"`
+	Properties []PropertyReport `json:"properties"`
+	Totals     []Total          `json:"totals"`
+}
+
+// NewReport assesses every booking against the rules for the given YYYY-MM month
+// and totals the result per property. Properties without bookings are listed with zero totals.
+func NewReport(groupID, month string, properties []database.Property, bookings []database.Booking, registrations []database.GuestRegistration, rules []database.TouristTaxRule) (Report, error) {
+	from, to, err := MonthBounds(month)
+	if err != nil {
+		return Report{}, err
+	}
+
+	r := Report{
+		GroupID:    groupID,
+		Month:      month,
+		Properties: make([]PropertyReport, len(properties)),
+		Totals:     []Total{},
+	}
+	index := make(map[string]int, len(properties))
+	for i, p := range properties {
+		index[p.ID] = i
+		r.Properties[i] = PropertyReport{PropertyID: p.ID, PropertyName: p.Name, Totals: []Total{}}
+	}
+
+	registered := make(map[string][]database.GuestRegistration)
+	for _, reg := range registrations {
+		registered[reg.BookingID] = append(registered[reg.BookingID], reg)
+	}
+
+	for _, b := range bookings {
+		i, ok := index[b.PropertyID]
+		if !ok {
+			continue
+		}
+		a, err := Assess(b, registered[b.ID], rules, from, to)
+		if err != nil {
+			return Report{}, err
+		}
+		if a.Nights == 0 {
+			continue
+		}
+		pr := &r.Properties[i]
+		pr.Bookings++
+		pr.Nights += a.Nights
+		pr.TaxableGuestNights += a.TaxableGuestNights
+		pr.UntaxedNights += a.UntaxedNights
+		for _, t := range a.Totals {
+			pr.Totals = addTotal(pr.Totals, t.Currency, t.Amount)
+			r.Totals = addTotal(r.Totals, t.Currency, t.Amount)
+		}
+	}
+
+	return r, nil
+}
+
+// MonthBounds returns the first day of a YYYY-MM month and the first day of the next one
+func MonthBounds(month string) (time.Time, time.Time, error) {
+	from, err := time.Parse("2006-01", month)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return from, from.AddDate(0, 1, 0), nil
+}
+
+// ageOn returns the age in whole years of someone born on birthDate at the given day
+func ageOn(birthDate, day time.Time) int {
+	age := day.Year() - birthDate.Year()
+	if day.Month() < birthDate.Month() || (day.Month() == birthDate.Month() && day.Day() < birthDate.Day()) {
+		age--
+	}
+	return age
+}
+
+// addTotal adds an amount to the total of its currency
+func addTotal(totals []Total, currency string, amount float64) []Total {
+	for i := range totals {
+		if totals[i].Currency == currency {
+			totals[i].Amount = round(totals[i].Amount + amount)
+			return totals
+		}
+	}
+	return append(totals, Total{Currency: currency, Amount: round(amount)})
+}
+
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
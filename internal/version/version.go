@@ -0,0 +1,41 @@
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Version is set at build time:
+//
+//	go build -ldflags "-X booker-be/internal/version.Version=1.4.0" ./cmd
+var Version = "dev"
+
+// Info describes the running build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	Modified  bool   `json:"modified"` // Built from a working tree with uncommitted changes
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the version together with the VCS details the Go toolchain
+// stamps into binaries built from a git checkout
+func Get() Info {
+	info := Info{Version: Version, GoVersion: runtime.Version()}
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, s := range build.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Commit = s.Value
+		case "vcs.time":
+			info.BuildTime = s.Value
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	return info
+}
@@ -0,0 +1,315 @@
+package webhooks
+
+import (
+	"booker-be/internal/database"
+	"booker-be/internal/events"
+	"booker-be/internal/protocol"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Headers sent with every delivery
+const (
+	EventHeader     = "X-Booker-Event"
+	DeliveryHeader  = "X-Booker-Delivery"
+	TimestampHeader = "X-Booker-Timestamp"
+	SignatureHeader = "X-Booker-Signature"
+)
+
+const (
+	maxAttempts     = 10
+	firstRetryDelay = 30 * time.Second
+	maxRetryDelay   = 6 * time.Hour
+	pollInterval    = 10 * time.Second
+	deliveryTimeout = 10 * time.Second
+	claimDuration   = time.Minute // Longer than an attempt can take
+	batchSize       = 20
+	maxErrorLength  = 500
+)
+
+// EventTypes lists the events a webhook can subscribe to
+var EventTypes = []string{
+	events.BookingCreated,
+	events.BookingUpdated,
+	events.BookingDeleted,
+}
+
+// Payload is the JSON body posted to a webhook. Bookings are sent without their
+// notes, which are meant for the group only.
+type Payload struct {
+	ID         string                   `json:"id"` // The delivery ID, unchanged between retries
+	Event      string                   `json:"event"`
+	GroupID    string                   `json:"group_id"`
+	ActorID    string                   `json:"actor_id"`
+	OccurredAt time.Time                `json:"occurred_at"`
+	Data       any                      `json:"data"`
+	Changes    map[string]events.Change `json:"changes,omitempty"`
+}
+
+// bookingData is a booking as sent to webhooks. Its empty Notes hides the notes of
+// the embedded response.
+type bookingData struct {
+	protocol.BookingResponse
+	Notes string `json:"notes,omitempty"`
+}
+
+// payloadData returns the data and changes of an event as sent to webhooks, and
+// false when nothing is left to send
+func payloadData(e events.Event) (any, map[string]events.Change, bool) {
+	booking, ok := e.Data.(protocol.BookingResponse)
+	if !ok {
+		return e.Data, e.Changes, true
+	}
+
+	changes := e.Changes
+	if _, ok := changes["notes"]; ok {
+		changes = maps.Clone(changes)
+		delete(changes, "notes")
+		// Every update changes the version, so only the version left means only the notes changed
+		if _, versioned := changes["version"]; len(changes) == 0 || versioned && len(changes) == 1 {
+			return nil, nil, false
+		}
+	}
+	return bookingData{BookingResponse: booking}, changes, true
+}
+
+// Dispatcher queues webhook deliveries in the database and sends them,
+// retrying failed ones with exponential backoff
+type Dispatcher struct {
+	db     database.Service
+	client *http.Client
+	wake   chan struct{}
+}
+
+func NewDispatcher(db database.Service) *Dispatcher {
+	return &Dispatcher{
+		db: db,
+		client: &http.Client{
+			Timeout: deliveryTimeout,
+			// A redirect is reported as a failed delivery instead of being followed
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// IsEventType reports whether webhooks can subscribe to an event type
+func IsEventType(eventType string) bool {
+	return slices.Contains(EventTypes, eventType)
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of "<timestamp>.<body>" keyed with the secret.
+// Receivers recompute it to check that a delivery is authentic and was not replayed later.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Enqueue stores a pending delivery of the event for every active webhook of its
+// group that subscribed to it. It is registered with events.Hub.OnPublish, so the
+// deliveries are persisted before the request that caused the event completes.
+func (d *Dispatcher) Enqueue(e events.Event) {
+	if !IsEventType(e.Type) {
+		return
+	}
+	data, changes, ok := payloadData(e)
+	if !ok {
+		return
+	}
+
+	hooks, err := d.db.GetWebhooksByGroupID(e.GroupID)
+	if err != nil {
+		slog.Error("Error retrieving webhooks", "group_id", e.GroupID, "event_id", e.ID, "error", err)
+		return
+	}
+
+	queued := false
+	for _, hook := range hooks {
+		if !hook.Active || !slices.Contains(hook.EventTypes, e.Type) {
+			continue
+		}
+
+		id := protocol.GenerateID()
+		body, err := json.Marshal(Payload{
+			ID:         id,
+			Event:      e.Type,
+			GroupID:    e.GroupID,
+			ActorID:    e.ActorID,
+			OccurredAt: e.Time,
+			Data:       data,
+			Changes:    changes,
+		})
+		if err != nil {
+			slog.Error("Error encoding webhook payload", "event_id", e.ID, "error", err)
+			return
+		}
+
+		err = d.db.InsertWebhookDelivery(database.WebhookDelivery{
+			ID:            id,
+			CreatedAt:     protocol.GetCurrentTime(),
+			WebhookID:     hook.ID,
+			EventType:     e.Type,
+			Payload:       string(body),
+			Status:        database.WebhookDeliveryPending,
+			NextAttemptAt: time.Now().Unix(),
+		})
+		if err != nil {
+			slog.Error("Error queueing webhook delivery", "webhook_id", hook.ID, "event_id", e.ID, "error", err)
+			continue
+		}
+		queued = true
+	}
+
+	if queued {
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run sends due deliveries until the context is cancelled. Deliveries queued
+// while the server was down are picked up when it starts again.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.deliverDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+func (d *Dispatcher) deliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		due, err := d.db.GetDueWebhookDeliveries(time.Now().Unix(), batchSize)
+		if err != nil {
+			slog.Error("Error retrieving due webhook deliveries", "error", err)
+			return
+		}
+
+		for _, delivery := range due {
+			// Another instance may have picked it up since it was listed
+			now := time.Now()
+			claimed, err := d.db.ClaimWebhookDelivery(delivery.ID, now.Unix(), now.Add(claimDuration).Unix())
+			if err != nil {
+				slog.Error("Error claiming webhook delivery", "delivery_id", delivery.ID, "error", err)
+				continue
+			}
+			if claimed {
+				d.attempt(ctx, delivery)
+			}
+		}
+		if len(due) < batchSize {
+			return
+		}
+	}
+}
+
+// attempt sends a delivery once and records the outcome
+func (d *Dispatcher) attempt(ctx context.Context, delivery database.WebhookDelivery) {
+	hook, err := d.db.GetWebhookByID(delivery.WebhookID)
+	if err != nil {
+		// The webhook was deleted together with its deliveries in the meantime
+		return
+	}
+
+	var status int
+	if hook.Active {
+		status, err = d.send(ctx, hook, delivery)
+		if ctx.Err() != nil {
+			// Shutting down; the attempt is repeated once the claim expires
+			return
+		}
+	} else {
+		err = fmt.Errorf("webhook is disabled")
+	}
+
+	delivery.Attempts++
+	delivery.ResponseStatus = status
+	switch {
+	case err == nil:
+		delivery.Status = database.WebhookDeliveryDelivered
+		delivery.DeliveredAt = protocol.GetCurrentTime()
+		delivery.LastError = ""
+	case !hook.Active || delivery.Attempts >= maxAttempts:
+		delivery.Status = database.WebhookDeliveryFailed
+		delivery.LastError = truncate(err.Error())
+	default:
+		delivery.NextAttemptAt = time.Now().Add(retryDelay(delivery.Attempts)).Unix()
+		delivery.LastError = truncate(err.Error())
+	}
+
+	if err := d.db.UpdateWebhookDeliveryAttempt(delivery); err != nil {
+		slog.Error("Error recording webhook delivery attempt", "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+// send posts the signed payload and returns the response status
+func (d *Dispatcher) send(ctx context.Context, hook database.Webhook, delivery database.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "booker-webhooks/1.0")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, "sha256="+Sign(hook.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Drain a little of the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// retryDelay doubles the delay after every failed attempt: 30s, 1m, 2m, ... up to 6h
+func retryDelay(attempts int) time.Duration {
+	delay := firstRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+func truncate(s string) string {
+	if len(s) <= maxErrorLength {
+		return s
+	}
+	return s[:maxErrorLength]
+}